@@ -77,6 +77,21 @@ type Sink interface {
 	Name() string
 }
 
+// SinkStopper is an optional interface that a Sink can implement to flush or
+// save buffered metrics when the monitor stops. Monitor.Stop calls Stop after
+// metrics collection has stopped.
+type SinkStopper interface {
+	Stop()
+}
+
+// SinkErrorReporter is an optional interface that a Sink can implement to report
+// errors and problems that Send does not return, like dropped metrics. The monitor
+// reports them in its collector status (proto.MonitorCollectorStatus.SinkErrors).
+type SinkErrorReporter interface {
+	// SinkError returns the current sink error, or an empty string if none.
+	SinkError() string
+}
+
 // SinkFactory makes a Sink for a monitor.
 type SinkFactory interface {
 	Make(SinkFactoryArgs) (Sink, error)
@@ -99,6 +99,15 @@ func (h CollectorHelp) Validate(opts map[string]string) error {
 	return nil
 }
 
+// OptionValidator is an optional interface that a Collector can implement to
+// validate option values that CollectorHelp.Validate cannot, like a query given
+// in a plan. The plan loader calls ValidateOptions after CollectorHelp.Validate,
+// so only options given in the plan are passed. The collector is made with
+// CollectorFactoryArgs.Validate = true, so it has no DB connection.
+type OptionValidator interface {
+	ValidateOptions(opts map[string]string) error
+}
+
 // CollectorFactoryArgs are provided by Blip to a CollectorFactory when making
 // a Collector. The factory must use the args to create the collector.
 type CollectorFactoryArgs struct {
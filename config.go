@@ -240,8 +240,9 @@ func (c *Config) InterpolateEnvVars() {
 // ///////////////////////////////////////////////////////////////////////////
 
 type ConfigAPI struct {
-	Bind    string `yaml:"bind"`
-	Disable bool   `yaml:"disable,omitempty"`
+	Bind          string `yaml:"bind"`
+	Disable       bool   `yaml:"disable,omitempty"`
+	ExporterMerge bool   `yaml:"exporter-merge,omitempty"`
 }
 
 const (
@@ -578,8 +579,9 @@ const (
 )
 
 type ConfigExporter struct {
-	Flags map[string]string `yaml:"flags,omitempty"`
-	Mode  string            `yaml:"mode,omitempty"`
+	Flags  map[string]string `yaml:"flags,omitempty"`
+	Mode   string            `yaml:"mode,omitempty"`
+	Shared *bool             `yaml:"shared,omitempty"`
 }
 
 func DefaultConfigExporter() ConfigExporter {
@@ -597,6 +599,7 @@ func (c *ConfigExporter) ApplyDefaults(b Config) {
 	if c.Mode == "" && b.Exporter.Mode != "" {
 		c.Mode = b.Exporter.Mode
 	}
+	c.Shared = setBool(c.Shared, b.Exporter.Shared)
 	if len(b.Exporter.Flags) > 0 {
 		if c.Flags == nil {
 			c.Flags = map[string]string{}
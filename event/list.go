@@ -46,6 +46,8 @@ const (
 	MONITOR_PANIC            = "monitor-panic"
 	MONITOR_STARTED          = "monitor-started"
 	MONITOR_STOPPED          = "monitor-stopped"
+	SINK_BREAKER_CLOSED      = "sink-breaker-closed"
+	SINK_BREAKER_OPEN        = "sink-breaker-open"
 	SINK_SEND_ERROR          = "sink-send-error"
 	STATE_CHANGE_ABORT       = "state-change-abort"
 	STATE_CHANGE_BEGIN       = "state-change-begin"
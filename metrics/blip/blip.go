@@ -0,0 +1,186 @@
+// Copyright 2022 Block, Inc.
+
+// Package blipmetrics provides the blip domain: Blip self-telemetry. Unlike other
+// collectors, it does not query MySQL. Monitor components (Engine, LPC, and
+// sinks) that implement Source are registered per monitor, and the collector
+// returns their metrics.
+package blipmetrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cashapp/blip"
+)
+
+const DOMAIN = "blip"
+
+// Source is a monitor component that reports Blip internal metrics. Metric
+// names must be listed in Blip.Help.
+type Source interface {
+	BlipMetrics() []blip.MetricValue
+}
+
+var sources = map[string][]Source{} // keyed on monitor ID
+var sourcesMux = &sync.Mutex{}
+
+// SetSources sets the sources for the monitor, replacing any previous sources.
+// The monitor calls this function each time it starts (or restarts) because it
+// makes new components.
+func SetSources(monitorId string, src ...Source) {
+	sourcesMux.Lock()
+	defer sourcesMux.Unlock()
+	sources[monitorId] = src
+}
+
+// RemoveSources removes the sources for the monitor when it stops.
+func RemoveSources(monitorId string) {
+	sourcesMux.Lock()
+	defer sourcesMux.Unlock()
+	delete(sources, monitorId)
+}
+
+// Blip collects Blip internal metrics for the blip domain.
+type Blip struct {
+	monitorId string
+	// --
+	metrics map[string]map[string]bool // keyed on level; nil = all metrics
+}
+
+var _ blip.Collector = &Blip{}
+
+func NewBlip(monitorId string) *Blip {
+	return &Blip{
+		monitorId: monitorId,
+		metrics:   map[string]map[string]bool{},
+	}
+}
+
+func (c *Blip) Domain() string {
+	return DOMAIN
+}
+
+func (c *Blip) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Blip internal metrics (self-telemetry) for the monitor",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "domain", Value: "domain name (only domain_* metrics)"},
+			{Key: "level", Value: "level name (only level_* metrics)"},
+			{Key: "sink", Value: "sink name (only sink_* metrics)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "collect_all",
+				Type: blip.COUNTER,
+				Desc: "Number of collections that returned all metrics",
+			},
+			{
+				Name: "collect_some",
+				Type: blip.COUNTER,
+				Desc: "Number of collections that returned some metrics (partial success)",
+			},
+			{
+				Name: "collect_fail",
+				Type: blip.COUNTER,
+				Desc: "Number of collections that failed (no metrics)",
+			},
+			{
+				Name: "domain_collect_latency",
+				Type: blip.GAUGE,
+				Desc: "Last collect time per domain (milliseconds)",
+			},
+			{
+				Name: "domain_collect_errors",
+				Type: blip.COUNTER,
+				Desc: "Number of collect errors per domain",
+			},
+			{
+				Name: "level_blocked",
+				Type: blip.COUNTER,
+				Desc: "Number of times a level was skipped because all collectors were still running",
+			},
+			{
+				Name: "sink_send_latency",
+				Type: blip.GAUGE,
+				Desc: "Last send time per sink (milliseconds)",
+			},
+			{
+				Name: "sink_send_errors",
+				Type: blip.COUNTER,
+				Desc: "Number of send errors returned to the monitor per sink",
+			},
+			{
+				Name: "sink_retry_errors",
+				Type: blip.COUNTER,
+				Desc: "Number of real sink send errors in Retry per sink",
+			},
+			{
+				Name: "sink_buffer_depth",
+				Type: blip.GAUGE,
+				Desc: "Number of metrics buffered by Retry per sink",
+			},
+			{
+				Name: "sink_dropped",
+				Type: blip.COUNTER,
+				Desc: "Number of metrics dropped by Retry per sink",
+			},
+			{
+				Name: "sink_spooled",
+				Type: blip.COUNTER,
+				Desc: "Number of metrics spooled to disk by Retry per sink",
+			},
+			{
+				Name: "sink_breaker_open",
+				Type: blip.GAUGE,
+				Desc: "1 if the Retry circuit breaker is open or half-open per sink, else 0",
+			},
+		},
+	}
+}
+
+// Prepare saves the metrics to collect at each level. If none are listed,
+// all metrics are collected.
+func (c *Blip) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	valid := map[string]bool{}
+	for _, m := range c.Help().Metrics {
+		valid[m.Name] = true
+	}
+	for levelName, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue // not collected in this level
+		}
+		if len(dom.Metrics) == 0 {
+			c.metrics[levelName] = nil
+			continue
+		}
+		c.metrics[levelName] = map[string]bool{}
+		for _, name := range dom.Metrics {
+			if !valid[name] {
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			c.metrics[levelName][name] = true
+		}
+	}
+	return nil, nil
+}
+
+func (c *Blip) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	sourcesMux.Lock()
+	src := sources[c.monitorId]
+	sourcesMux.Unlock()
+
+	only := c.metrics[levelName]
+	metrics := []blip.MetricValue{}
+	for i := range src {
+		for _, m := range src[i].BlipMetrics() {
+			if only != nil && !only[m.Name] {
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Block, Inc.
+
+package blipmetrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/blip"
+)
+
+type source []blip.MetricValue
+
+func (s source) BlipMetrics() []blip.MetricValue {
+	return s
+}
+
+func TestCollect(t *testing.T) {
+	engine := source{
+		{Name: "collect_all", Type: blip.COUNTER, Value: 10},
+		{Name: "collect_fail", Type: blip.COUNTER, Value: 1},
+	}
+	lpc := source{
+		{Name: "sink_send_latency", Type: blip.GAUGE, Value: 2.5, Group: map[string]string{"sink": "signalfx"}},
+	}
+	blipmetrics.SetSources("m1", engine, lpc)
+	defer blipmetrics.RemoveSources("m1")
+
+	plan := blip.Plan{
+		Levels: map[string]blip.Level{
+			"all": {
+				Collect: map[string]blip.Domain{
+					blipmetrics.DOMAIN: {},
+				},
+			},
+			"some": {
+				Collect: map[string]blip.Domain{
+					blipmetrics.DOMAIN: {Metrics: []string{"collect_fail", "sink_send_latency"}},
+				},
+			},
+		},
+	}
+	c := blipmetrics.NewBlip("m1")
+	if _, err := c.Prepare(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Collect(context.Background(), "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := append(append([]blip.MetricValue{}, engine...), lpc...)
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	got, err = c.Collect(context.Background(), "some")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = []blip.MetricValue{engine[1], lpc[0]}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Monitor stopped: no sources, no metrics, no error
+	blipmetrics.RemoveSources("m1")
+	got, err = c.Collect(context.Background(), "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d metrics, expected 0", len(got))
+	}
+
+	// Invalid metric
+	plan.Levels["some"].Collect[blipmetrics.DOMAIN] = blip.Domain{Metrics: []string{"collect_al"}}
+	if _, err := blipmetrics.NewBlip("m1").Prepare(context.Background(), plan); err == nil {
+		t.Error("no error for invalid metric, expected an error")
+	}
+}
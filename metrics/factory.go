@@ -9,13 +9,22 @@ import (
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/metrics/aws.rds"
+	"github.com/cashapp/blip/metrics/blip"
 	"github.com/cashapp/blip/metrics/innodb"
+	"github.com/cashapp/blip/metrics/os"
 	"github.com/cashapp/blip/metrics/percona"
+	"github.com/cashapp/blip/metrics/processlist"
+	"github.com/cashapp/blip/metrics/query.global"
 	"github.com/cashapp/blip/metrics/repl"
+	"github.com/cashapp/blip/metrics/repl.group"
 	"github.com/cashapp/blip/metrics/repl.lag"
 	"github.com/cashapp/blip/metrics/size.binlog"
 	"github.com/cashapp/blip/metrics/size.database"
+	"github.com/cashapp/blip/metrics/size.table"
+	"github.com/cashapp/blip/metrics/sql.custom"
 	"github.com/cashapp/blip/metrics/status.global"
+	"github.com/cashapp/blip/metrics/stmt.digest"
+	"github.com/cashapp/blip/metrics/trx"
 	"github.com/cashapp/blip/metrics/var.global"
 )
 
@@ -225,20 +234,38 @@ func (f *factory) Make(domain string, args blip.CollectorFactoryArgs) (blip.Coll
 			return nil, err
 		}
 		return awsrds.NewRDS(awsrds.NewCloudWatchClient(awsConfig)), nil
+	case "blip":
+		return blipmetrics.NewBlip(args.MonitorId), nil
 	case "innodb":
 		return innodb.NewInnoDB(args.DB), nil
+	case "os":
+		return osmetrics.NewOS(args.DB, args.Config), nil
 	case "percona.response-time":
 		return percona.NewQRT(args.DB), nil
+	case "processlist":
+		return processlist.NewProcesslist(args.DB), nil
+	case "query.global":
+		return queryglobal.NewGlobal(args.DB), nil
 	case "repl":
 		return repl.NewRepl(args.DB), nil
+	case "repl.group":
+		return replgroup.NewGroup(args.DB), nil
 	case "repl.lag":
 		return repllag.NewLag(args.DB), nil
 	case "size.binlog":
 		return sizebinlog.NewBinlog(args.DB), nil
 	case "size.database":
 		return sizedatabase.NewDatabase(args.DB), nil
+	case "size.table":
+		return sizetable.NewTable(args.DB), nil
+	case "sql.custom":
+		return sqlcustom.NewCustom(args.DB), nil
 	case "status.global":
 		return statusglobal.NewGlobal(args.DB), nil
+	case "stmt.digest":
+		return stmtdigest.NewDigest(args.DB), nil
+	case "trx":
+		return trx.NewTrx(args.DB), nil
 	case "var.global":
 		return varglobal.NewGlobal(args.DB), nil
 	}
@@ -249,12 +276,21 @@ func (f *factory) Make(domain string, args blip.CollectorFactoryArgs) (blip.Coll
 // the same domain in the switch statement above (in factory.Make).
 var builtinCollectors = []string{
 	"aws.rds",
+	"blip",
 	"innodb",
+	"os",
 	"percona.response-time",
+	"processlist",
+	"query.global",
 	"repl",
+	"repl.group",
 	"repl.lag",
 	"size.binlog",
 	"size.database",
+	"size.table",
+	"sql.custom",
 	"status.global",
+	"stmt.digest",
+	"trx",
 	"var.global",
 }
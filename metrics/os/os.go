@@ -0,0 +1,313 @@
+// Copyright 2022 Block, Inc.
+
+// Package osmetrics provides the os domain collector, which reports host
+// metrics when Blip runs on the same host as MySQL.
+package osmetrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "os"
+
+	OPT_SOURCES    = "sources"
+	OPT_PROC       = "proc"
+	OPT_DEVICES    = "devices"
+	OPT_INTERFACES = "interfaces"
+
+	SOURCE_STAT      = "stat"
+	SOURCE_MEMINFO   = "meminfo"
+	SOURCE_DISKSTATS = "diskstats"
+	SOURCE_NETDEV    = "netdev"
+	SOURCE_DATADIR   = "datadir"
+
+	DEFAULT_SOURCES = "stat,meminfo,diskstats,netdev,datadir"
+	DEFAULT_PROC    = "/proc"
+)
+
+// Filesystem is filesystem usage returned by Statfs.
+type Filesystem struct {
+	Size      float64 // bytes
+	Free      float64 // bytes
+	Avail     float64 // bytes available to unprivileged users
+	Files     float64 // inodes
+	FilesFree float64 // free inodes
+}
+
+type levelConfig struct {
+	proc       string
+	sources    map[string]bool
+	devices    map[string]bool // nil = all except loop and ram
+	interfaces map[string]bool // nil = all except lo
+}
+
+// OS collects host metrics for the os domain. The sources are files in /proc
+// and filesystem usage for the MySQL datadir, so Blip must run on the same
+// host as MySQL, which is the case when monitors are auto-detected locally.
+type OS struct {
+	db  *sql.DB
+	cfg blip.ConfigMonitor
+	// --
+	atLevel map[string]levelConfig
+	datadir string
+}
+
+var _ blip.Collector = &OS{}
+
+func NewOS(db *sql.DB, cfg blip.ConfigMonitor) *OS {
+	return &OS{
+		db:      db,
+		cfg:     cfg,
+		atLevel: map[string]levelConfig{},
+	}
+}
+
+// Local returns true if the monitor connects to MySQL on the same host as Blip:
+// by socket, or by TCP to localhost or a loopback address. Monitors auto-detected
+// locally (config.monitor-loader.local) connect this way.
+func Local(cfg blip.ConfigMonitor) bool {
+	if cfg.Socket != "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(cfg.Hostname)
+	if err != nil {
+		host = cfg.Hostname // no port
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (c *OS) Domain() string {
+	return DOMAIN
+}
+
+func (c *OS) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Host metrics like 'cpu_user' and 'disk_io_time' (Blip must run on MySQL host)",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_SOURCES: {
+				Name:    OPT_SOURCES,
+				Desc:    "Comma-separated list of sources: " + DEFAULT_SOURCES,
+				Default: DEFAULT_SOURCES,
+			},
+			OPT_PROC: {
+				Name:    OPT_PROC,
+				Desc:    "Path to procfs (change if Blip runs in a container with host /proc mounted elsewhere)",
+				Default: DEFAULT_PROC,
+			},
+			OPT_DEVICES: {
+				Name: OPT_DEVICES,
+				Desc: "Comma-separated list of disk devices to report (default: all except loop and ram)",
+			},
+			OPT_INTERFACES: {
+				Name: OPT_INTERFACES,
+				Desc: "Comma-separated list of network interfaces to report (default: all except lo)",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "device", Value: "disk device (only disk_* metrics)"},
+			{Key: "interface", Value: "network interface (only net_* metrics)"},
+			{Key: "path", Value: "MySQL datadir (only fs_* metrics)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{Name: "cpu_user", Type: blip.COUNTER, Desc: "CPU time in user mode (seconds)"},
+			{Name: "cpu_nice", Type: blip.COUNTER, Desc: "CPU time in user mode with low priority (seconds)"},
+			{Name: "cpu_system", Type: blip.COUNTER, Desc: "CPU time in system mode (seconds)"},
+			{Name: "cpu_idle", Type: blip.COUNTER, Desc: "CPU idle time (seconds)"},
+			{Name: "cpu_iowait", Type: blip.COUNTER, Desc: "CPU time waiting for IO (seconds)"},
+			{Name: "cpu_irq", Type: blip.COUNTER, Desc: "CPU time servicing interrupts (seconds)"},
+			{Name: "cpu_softirq", Type: blip.COUNTER, Desc: "CPU time servicing softirqs (seconds)"},
+			{Name: "cpu_steal", Type: blip.COUNTER, Desc: "CPU time stolen by hypervisor (seconds)"},
+			{Name: "context_switches", Type: blip.COUNTER, Desc: "Context switches"},
+			{Name: "procs_running", Type: blip.GAUGE, Desc: "Processes running"},
+			{Name: "procs_blocked", Type: blip.GAUGE, Desc: "Processes blocked waiting for IO"},
+			{Name: "mem_total", Type: blip.GAUGE, Desc: "Total memory (bytes)"},
+			{Name: "mem_free", Type: blip.GAUGE, Desc: "Free memory (bytes)"},
+			{Name: "mem_available", Type: blip.GAUGE, Desc: "Available memory (bytes)"},
+			{Name: "mem_buffers", Type: blip.GAUGE, Desc: "Buffers memory (bytes)"},
+			{Name: "mem_cached", Type: blip.GAUGE, Desc: "Page cache memory (bytes)"},
+			{Name: "mem_dirty", Type: blip.GAUGE, Desc: "Dirty memory waiting to be written to disk (bytes)"},
+			{Name: "swap_total", Type: blip.GAUGE, Desc: "Total swap (bytes)"},
+			{Name: "swap_free", Type: blip.GAUGE, Desc: "Free swap (bytes)"},
+			{Name: "disk_reads", Type: blip.COUNTER, Desc: "Reads completed"},
+			{Name: "disk_read_bytes", Type: blip.COUNTER, Desc: "Bytes read"},
+			{Name: "disk_read_time", Type: blip.COUNTER, Desc: "Time spent reading (milliseconds)"},
+			{Name: "disk_writes", Type: blip.COUNTER, Desc: "Writes completed"},
+			{Name: "disk_write_bytes", Type: blip.COUNTER, Desc: "Bytes written"},
+			{Name: "disk_write_time", Type: blip.COUNTER, Desc: "Time spent writing (milliseconds)"},
+			{Name: "disk_io_in_progress", Type: blip.GAUGE, Desc: "IOs in progress"},
+			{Name: "disk_io_time", Type: blip.COUNTER, Desc: "Time spent doing IOs (milliseconds)"},
+			{Name: "net_rx_bytes", Type: blip.COUNTER, Desc: "Bytes received"},
+			{Name: "net_rx_packets", Type: blip.COUNTER, Desc: "Packets received"},
+			{Name: "net_rx_errors", Type: blip.COUNTER, Desc: "Receive errors"},
+			{Name: "net_rx_drop", Type: blip.COUNTER, Desc: "Received packets dropped"},
+			{Name: "net_tx_bytes", Type: blip.COUNTER, Desc: "Bytes transmitted"},
+			{Name: "net_tx_packets", Type: blip.COUNTER, Desc: "Packets transmitted"},
+			{Name: "net_tx_errors", Type: blip.COUNTER, Desc: "Transmit errors"},
+			{Name: "net_tx_drop", Type: blip.COUNTER, Desc: "Transmitted packets dropped"},
+			{Name: "fs_size", Type: blip.GAUGE, Desc: "Datadir filesystem size (bytes)"},
+			{Name: "fs_free", Type: blip.GAUGE, Desc: "Datadir filesystem free space (bytes)"},
+			{Name: "fs_avail", Type: blip.GAUGE, Desc: "Datadir filesystem space available to unprivileged users (bytes)"},
+			{Name: "fs_files", Type: blip.GAUGE, Desc: "Datadir filesystem inodes"},
+			{Name: "fs_files_free", Type: blip.GAUGE, Desc: "Datadir filesystem free inodes"},
+		},
+	}
+}
+
+// Prepare prepares sources for all levels in the plan that contain the os domain.
+func (c *OS) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		cfg := levelConfig{
+			proc:    blip.SetOrDefault(dom.Options[OPT_PROC], DEFAULT_PROC),
+			sources: map[string]bool{},
+		}
+		for _, src := range list(blip.SetOrDefault(dom.Options[OPT_SOURCES], DEFAULT_SOURCES)) {
+			switch src {
+			case SOURCE_STAT, SOURCE_MEMINFO, SOURCE_DISKSTATS, SOURCE_NETDEV, SOURCE_DATADIR:
+				cfg.sources[src] = true
+			default:
+				return nil, fmt.Errorf("invalid %s value: %s; valid values: %s", OPT_SOURCES, src, DEFAULT_SOURCES)
+			}
+		}
+		if v := dom.Options[OPT_DEVICES]; v != "" {
+			cfg.devices = set(list(v))
+		}
+		if v := dom.Options[OPT_INTERFACES]; v != "" {
+			cfg.interfaces = set(list(v))
+		}
+
+		// Make sure Blip is running on the MySQL host: the monitor connects
+		// locally, /proc/stat exists, and the MySQL datadir is a local path.
+		// Otherwise, Blip would report its own host metrics as the MySQL host.
+		if !Local(c.cfg) {
+			return nil, fmt.Errorf("%s domain requires a local MySQL connection (socket, localhost, or 127.0.0.1), but monitor %s connects to %s", DOMAIN, c.cfg.MonitorId, c.cfg.Hostname)
+		}
+		if _, err := ioutil.ReadFile(filepath.Join(cfg.proc, "stat")); err != nil {
+			return nil, fmt.Errorf("cannot read %s/stat (%s domain requires Linux): %s", cfg.proc, DOMAIN, err)
+		}
+		if cfg.sources[SOURCE_DATADIR] && c.datadir == "" {
+			var datadir string
+			if err := c.db.QueryRowContext(ctx, "SELECT @@GLOBAL.datadir").Scan(&datadir); err != nil {
+				return nil, err
+			}
+			if _, err := Statfs(datadir); err != nil {
+				return nil, fmt.Errorf("cannot stat MySQL datadir %s (%s domain requires Blip to run on the MySQL host): %s", datadir, DOMAIN, err)
+			}
+			c.datadir = datadir
+		}
+
+		c.atLevel[level.Name] = cfg
+	}
+	return nil, nil
+}
+
+func (c *OS) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	cfg, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+
+	metrics := []blip.MetricValue{}
+
+	if cfg.sources[SOURCE_STAT] {
+		buf, err := ioutil.ReadFile(filepath.Join(cfg.proc, "stat"))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseStat(buf)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if cfg.sources[SOURCE_MEMINFO] {
+		buf, err := ioutil.ReadFile(filepath.Join(cfg.proc, "meminfo"))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseMeminfo(buf)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if cfg.sources[SOURCE_DISKSTATS] {
+		buf, err := ioutil.ReadFile(filepath.Join(cfg.proc, "diskstats"))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseDiskstats(buf, cfg.devices)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if cfg.sources[SOURCE_NETDEV] {
+		buf, err := ioutil.ReadFile(filepath.Join(cfg.proc, "net", "dev"))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseNetDev(buf, cfg.interfaces)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if cfg.sources[SOURCE_DATADIR] {
+		fs, err := Statfs(c.datadir)
+		if err != nil {
+			return nil, err
+		}
+		group := map[string]string{"path": c.datadir}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "fs_size", Type: blip.GAUGE, Value: fs.Size, Group: group},
+			blip.MetricValue{Name: "fs_free", Type: blip.GAUGE, Value: fs.Free, Group: group},
+			blip.MetricValue{Name: "fs_avail", Type: blip.GAUGE, Value: fs.Avail, Group: group},
+			blip.MetricValue{Name: "fs_files", Type: blip.GAUGE, Value: fs.Files, Group: group},
+			blip.MetricValue{Name: "fs_files_free", Type: blip.GAUGE, Value: fs.FilesFree, Group: group},
+		)
+	}
+
+	return metrics, nil
+}
+
+func list(csv string) []string {
+	var l []string
+	for _, v := range strings.Split(csv, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func set(l []string) map[string]bool {
+	m := make(map[string]bool, len(l))
+	for _, v := range l {
+		m[v] = true
+	}
+	return m
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 Block, Inc.
+
+package osmetrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cashapp/blip"
+)
+
+// USER_HZ is the kernel clock tick rate for /proc/stat CPU times. It is 100
+// on all common Linux platforms.
+const USER_HZ = 100
+
+// Linux reports disk sectors in 512-byte units regardless of device sector size.
+const sectorSize = 512
+
+var cpuFields = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+// ParseStat parses /proc/stat: total CPU time (seconds) from the "cpu" line,
+// context switches, and running and blocked processes.
+func ParseStat(buf []byte) ([]blip.MetricValue, error) {
+	metrics := []blip.MetricValue{}
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "cpu": // total of all CPUs, not "cpu0", "cpu1", etc.
+			for i, name := range cpuFields {
+				if i+1 >= len(f) {
+					break // older kernels don't have all fields
+				}
+				n, err := strconv.ParseFloat(f[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("/proc/stat cpu %s: %s", name, err)
+				}
+				metrics = append(metrics, blip.MetricValue{
+					Name:  "cpu_" + name,
+					Type:  blip.COUNTER,
+					Value: n / USER_HZ,
+				})
+			}
+		case "ctxt", "procs_running", "procs_blocked":
+			n, err := strconv.ParseFloat(f[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/stat %s: %s", f[0], err)
+			}
+			m := blip.MetricValue{Value: n}
+			if f[0] == "ctxt" {
+				m.Name = "context_switches"
+				m.Type = blip.COUNTER
+			} else {
+				m.Name = f[0]
+				m.Type = blip.GAUGE
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, s.Err()
+}
+
+// meminfo maps /proc/meminfo fields to metric names.
+var meminfo = map[string]string{
+	"MemTotal:":     "mem_total",
+	"MemFree:":      "mem_free",
+	"MemAvailable:": "mem_available",
+	"Buffers:":      "mem_buffers",
+	"Cached:":       "mem_cached",
+	"Dirty:":        "mem_dirty",
+	"SwapTotal:":    "swap_total",
+	"SwapFree:":     "swap_free",
+}
+
+// ParseMeminfo parses /proc/meminfo. Values are converted from kB to bytes.
+func ParseMeminfo(buf []byte) ([]blip.MetricValue, error) {
+	metrics := []blip.MetricValue{}
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 {
+			continue
+		}
+		name, ok := meminfo[f[0]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(f[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("/proc/meminfo %s %s", f[0], err)
+		}
+		if len(f) == 3 && f[2] == "kB" {
+			n *= 1024
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  name,
+			Type:  blip.GAUGE,
+			Value: n,
+		})
+	}
+	return metrics, s.Err()
+}
+
+// ParseDiskstats parses /proc/diskstats. Metrics are grouped by device.
+// If devices is not nil, only those devices are reported; else, all devices
+// except loop and ram devices are reported.
+func ParseDiskstats(buf []byte, devices map[string]bool) ([]blip.MetricValue, error) {
+	metrics := []blip.MetricValue{}
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		//   8       0 sda 9731 2788 598774 4466 15208 10367 733426 20394 0 24140 26136
+		f := strings.Fields(s.Text())
+		if len(f) < 14 {
+			continue
+		}
+		dev := f[2]
+		if devices != nil {
+			if !devices[dev] {
+				continue
+			}
+		} else if strings.HasPrefix(dev, "loop") || strings.HasPrefix(dev, "ram") {
+			continue
+		}
+
+		var v [11]float64
+		for i := range v {
+			n, err := strconv.ParseFloat(f[3+i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/diskstats %s field %d: %s", dev, 4+i, err)
+			}
+			v[i] = n
+		}
+
+		group := map[string]string{"device": dev}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "disk_reads", Type: blip.COUNTER, Value: v[0], Group: group},
+			blip.MetricValue{Name: "disk_read_bytes", Type: blip.COUNTER, Value: v[2] * sectorSize, Group: group},
+			blip.MetricValue{Name: "disk_read_time", Type: blip.COUNTER, Value: v[3], Group: group},
+			blip.MetricValue{Name: "disk_writes", Type: blip.COUNTER, Value: v[4], Group: group},
+			blip.MetricValue{Name: "disk_write_bytes", Type: blip.COUNTER, Value: v[6] * sectorSize, Group: group},
+			blip.MetricValue{Name: "disk_write_time", Type: blip.COUNTER, Value: v[7], Group: group},
+			blip.MetricValue{Name: "disk_io_in_progress", Type: blip.GAUGE, Value: v[8], Group: group},
+			blip.MetricValue{Name: "disk_io_time", Type: blip.COUNTER, Value: v[9], Group: group},
+		)
+	}
+	return metrics, s.Err()
+}
+
+// ParseNetDev parses /proc/net/dev. Metrics are grouped by interface.
+// If interfaces is not nil, only those interfaces are reported; else, all
+// interfaces except loopback (lo) are reported.
+func ParseNetDev(buf []byte, interfaces map[string]bool) ([]blip.MetricValue, error) {
+	metrics := []blip.MetricValue{}
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		// Inter-|   Receive                                                |  Transmit
+		//  face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+		//   eth0: 1822375   2543    0    0    0     0          0         0   308374    2275    0    0    0     0       0          0
+		line := s.Text()
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue // header
+		}
+		iface := strings.TrimSpace(line[:colon])
+		if interfaces != nil {
+			if !interfaces[iface] {
+				continue
+			}
+		} else if iface == "lo" {
+			continue
+		}
+
+		f := strings.Fields(line[colon+1:])
+		if len(f) < 16 {
+			continue
+		}
+		var v [16]float64
+		for i := range v {
+			n, err := strconv.ParseFloat(f[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/net/dev %s field %d: %s", iface, i+1, err)
+			}
+			v[i] = n
+		}
+
+		group := map[string]string{"interface": iface}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "net_rx_bytes", Type: blip.COUNTER, Value: v[0], Group: group},
+			blip.MetricValue{Name: "net_rx_packets", Type: blip.COUNTER, Value: v[1], Group: group},
+			blip.MetricValue{Name: "net_rx_errors", Type: blip.COUNTER, Value: v[2], Group: group},
+			blip.MetricValue{Name: "net_rx_drop", Type: blip.COUNTER, Value: v[3], Group: group},
+			blip.MetricValue{Name: "net_tx_bytes", Type: blip.COUNTER, Value: v[8], Group: group},
+			blip.MetricValue{Name: "net_tx_packets", Type: blip.COUNTER, Value: v[9], Group: group},
+			blip.MetricValue{Name: "net_tx_errors", Type: blip.COUNTER, Value: v[10], Group: group},
+			blip.MetricValue{Name: "net_tx_drop", Type: blip.COUNTER, Value: v[11], Group: group},
+		)
+	}
+	return metrics, s.Err()
+}
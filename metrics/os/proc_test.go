@@ -0,0 +1,185 @@
+// Copyright 2022 Block, Inc.
+
+package osmetrics_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/os"
+)
+
+func values(metrics []blip.MetricValue) map[string]float64 {
+	m := map[string]float64{}
+	for _, v := range metrics {
+		key := v.Name
+		for _, g := range []string{"device", "interface"} {
+			if v.Group[g] != "" {
+				key = v.Group[g] + "." + key
+			}
+		}
+		m[key] = v.Value
+	}
+	return m
+}
+
+func TestParseStat(t *testing.T) {
+	buf := []byte(`cpu  1000 20 300 40000 50 0 6 0 0 0
+cpu0 500 10 150 20000 25 0 3 0 0 0
+intr 123456 0 0
+ctxt 987654
+btime 1650000000
+processes 4321
+procs_running 3
+procs_blocked 1
+`)
+	metrics, err := osmetrics.ParseStat(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := values(metrics)
+	expect := map[string]float64{
+		"cpu_user":         10,
+		"cpu_nice":         0.2,
+		"cpu_system":       3,
+		"cpu_idle":         400,
+		"cpu_iowait":       0.5,
+		"cpu_irq":          0,
+		"cpu_softirq":      0.06,
+		"cpu_steal":        0,
+		"context_switches": 987654,
+		"procs_running":    3,
+		"procs_blocked":    1,
+	}
+	if len(got) != len(expect) {
+		t.Errorf("got %d metrics, expected %d: %v", len(got), len(expect), got)
+	}
+	for k, v := range expect {
+		if got[k] != v {
+			t.Errorf("%s: got %f, expected %f", k, got[k], v)
+		}
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	buf := []byte(`MemTotal:        2048 kB
+MemFree:         1024 kB
+MemAvailable:    1536 kB
+Buffers:            1 kB
+Cached:           256 kB
+SwapCached:         0 kB
+Dirty:              2 kB
+SwapTotal:          0 kB
+SwapFree:           0 kB
+HugePages_Total:    0
+`)
+	metrics, err := osmetrics.ParseMeminfo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := values(metrics)
+	if len(got) != 8 {
+		t.Errorf("got %d metrics, expected 8: %v", len(got), got)
+	}
+	if got["mem_total"] != 2048*1024 {
+		t.Errorf("mem_total: got %f, expected %d", got["mem_total"], 2048*1024)
+	}
+	if got["mem_available"] != 1536*1024 {
+		t.Errorf("mem_available: got %f, expected %d", got["mem_available"], 1536*1024)
+	}
+}
+
+func TestParseDiskstats(t *testing.T) {
+	buf := []byte(`   7       0 loop0 10 0 20 0 0 0 0 0 0 4 0 0 0 0 0
+   8       0 sda 9731 2788 598774 4466 15208 10367 733426 20394 2 24140 26136 0 0 0 0
+   8       1 sda1 9000 2700 500000 4000 15000 10000 700000 20000 0 24000 26000 0 0 0 0
+`)
+	metrics, err := osmetrics.ParseDiskstats(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := values(metrics)
+	if _, ok := got["loop0.disk_reads"]; ok {
+		t.Error("loop0 reported, expected it to be skipped")
+	}
+	expect := map[string]float64{
+		"sda.disk_reads":          9731,
+		"sda.disk_read_bytes":     598774 * 512,
+		"sda.disk_read_time":      4466,
+		"sda.disk_writes":         15208,
+		"sda.disk_write_bytes":    733426 * 512,
+		"sda.disk_write_time":     20394,
+		"sda.disk_io_in_progress": 2,
+		"sda.disk_io_time":        24140,
+	}
+	for k, v := range expect {
+		if got[k] != v {
+			t.Errorf("%s: got %f, expected %f", k, got[k], v)
+		}
+	}
+
+	// Only sda1
+	metrics, err = osmetrics.ParseDiskstats(buf, map[string]bool{"sda1": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = values(metrics)
+	if len(got) != 8 || got["sda1.disk_reads"] != 9000 {
+		t.Errorf("got %v, expected only sda1 metrics", got)
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	buf := []byte(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  100      2    0    0    0     0          0         0      100       2    0    0    0     0       0          0
+  eth0: 1822375   2543    1    2    0     0          0         0   308374    2275    3    4    0     0       0          0
+`)
+	metrics, err := osmetrics.ParseNetDev(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := values(metrics)
+	expect := map[string]float64{
+		"eth0.net_rx_bytes":   1822375,
+		"eth0.net_rx_packets": 2543,
+		"eth0.net_rx_errors":  1,
+		"eth0.net_rx_drop":    2,
+		"eth0.net_tx_bytes":   308374,
+		"eth0.net_tx_packets": 2275,
+		"eth0.net_tx_errors":  3,
+		"eth0.net_tx_drop":    4,
+	}
+	if len(got) != len(expect) {
+		t.Errorf("got %d metrics, expected %d: %v", len(got), len(expect), got)
+	}
+	for k, v := range expect {
+		if got[k] != v {
+			t.Errorf("%s: got %f, expected %f", k, got[k], v)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	local := []blip.ConfigMonitor{
+		{Socket: "/var/run/mysqld/mysqld.sock"},
+		{Hostname: "localhost"},
+		{Hostname: "127.0.0.1:3306"},
+		{Hostname: "[::1]:3306"},
+	}
+	for _, cfg := range local {
+		if !osmetrics.Local(cfg) {
+			t.Errorf("%+v: Local = false, expected true", cfg)
+		}
+	}
+	remote := []blip.ConfigMonitor{
+		{Hostname: "db1.example.com:3306"},
+		{Hostname: "10.0.0.5:3306"},
+		{Hostname: "10.0.0.5"},
+	}
+	for _, cfg := range remote {
+		if osmetrics.Local(cfg) {
+			t.Errorf("%+v: Local = true, expected false", cfg)
+		}
+	}
+}
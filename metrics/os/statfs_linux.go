@@ -0,0 +1,26 @@
+// Copyright 2022 Block, Inc.
+
+//go:build linux
+// +build linux
+
+package osmetrics
+
+import (
+	"syscall"
+)
+
+// Statfs returns filesystem usage for the filesystem that contains path.
+func Statfs(path string) (Filesystem, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Filesystem{}, err
+	}
+	bsize := float64(st.Bsize)
+	return Filesystem{
+		Size:      float64(st.Blocks) * bsize,
+		Free:      float64(st.Bfree) * bsize,
+		Avail:     float64(st.Bavail) * bsize,
+		Files:     float64(st.Files),
+		FilesFree: float64(st.Ffree),
+	}, nil
+}
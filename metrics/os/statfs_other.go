@@ -0,0 +1,16 @@
+// Copyright 2022 Block, Inc.
+
+//go:build !linux
+// +build !linux
+
+package osmetrics
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Statfs returns an error because filesystem usage is only supported on Linux.
+func Statfs(path string) (Filesystem, error) {
+	return Filesystem{}, fmt.Errorf("filesystem usage not supported on %s", runtime.GOOS)
+}
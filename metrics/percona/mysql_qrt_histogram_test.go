@@ -6,6 +6,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/metrics/percona"
 )
 
@@ -69,3 +72,22 @@ func TestPercentile(t *testing.T) {
 		}
 	}
 }
+
+func TestHistogramMetrics(t *testing.T) {
+	buckets := []percona.QRTBucket{
+		{Time: 0.000001, Count: 2, Total: 0.000001},
+		{Time: 0.1, Count: 3, Total: 0.2},
+		{Time: 10, Count: 0, Total: 0},
+	}
+	got := percona.HistogramMetrics(buckets)
+	expect := []blip.MetricValue{
+		{Name: "response_time_bucket", Type: blip.COUNTER, Value: 2, Group: map[string]string{"le": "0.000001"}},
+		{Name: "response_time_bucket", Type: blip.COUNTER, Value: 5, Group: map[string]string{"le": "0.1"}},
+		{Name: "response_time_bucket", Type: blip.COUNTER, Value: 5, Group: map[string]string{"le": "10"}},
+		{Name: "response_time_count", Type: blip.COUNTER, Value: 5},
+		{Name: "response_time_sum", Type: blip.COUNTER, Value: 0.200001},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
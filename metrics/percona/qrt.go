@@ -43,6 +43,7 @@ const (
 	OPT_PERCENTILES           = "percentiles"
 	OPT_OPTIONAL              = "optional"
 	OPT_FLUSH_QRT             = "flush"
+	OPT_HISTOGRAM             = "histogram"
 	default_percentile_option = "999"
 )
 
@@ -57,6 +58,7 @@ type QRT struct {
 	percentiles map[string]map[float64]float64
 	optional    map[string]bool
 	flushQrt    map[string]bool
+	histogram   map[string]bool
 }
 
 func NewQRT(db *sql.DB) *QRT {
@@ -64,6 +66,8 @@ func NewQRT(db *sql.DB) *QRT {
 		db:          db,
 		percentiles: map[string]map[float64]float64{},
 		optional:    map[string]bool{},
+		flushQrt:    map[string]bool{},
+		histogram:   map[string]bool{},
 		available:   true,
 	}
 }
@@ -101,15 +105,54 @@ func (c *QRT) Help() blip.CollectorHelp {
 					"no":  "Do not flush Query Response Time (QRT) after each retrieval.",
 				},
 			},
+			OPT_HISTOGRAM: {
+				Name:    OPT_HISTOGRAM,
+				Desc:    "If the QRT histogram (buckets) is reported. Set " + OPT_FLUSH_QRT + "=no because counts are cumulative.",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Report metrics response_time_bucket, response_time_count, and response_time_sum",
+					"no":  "Report only percentiles (metric response_time)",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "le", Value: "bucket upper bound in seconds (only metric response_time_bucket)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "pN", Value: "Configured percentile N and actual percentile (value) (only metric response_time)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "response_time",
+				Type: blip.GAUGE,
+				Desc: "Response time percentile (microseconds)",
+			},
+			{
+				Name: "response_time_bucket",
+				Type: blip.COUNTER,
+				Desc: "Cumulative number of queries with response time less than or equal to bucket (option " + OPT_HISTOGRAM + ")",
+			},
+			{
+				Name: "response_time_count",
+				Type: blip.COUNTER,
+				Desc: "Number of queries (option " + OPT_HISTOGRAM + ")",
+			},
+			{
+				Name: "response_time_sum",
+				Type: blip.COUNTER,
+				Desc: "Total response time of all queries in seconds (option " + OPT_HISTOGRAM + ")",
+			},
 		},
 	}
 }
 
 // Prepare Prepares options for all levels in the plan that contain the percona.response-time domain
 func (c *QRT) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
-	_, err := c.db.Query(query)
+	rows, err := c.db.QueryContext(ctx, query)
 	if err != nil {
 		c.available = false
+	} else {
+		rows.Close()
 	}
 
 LEVEL:
@@ -182,12 +225,16 @@ func (c *QRT) Collect(ctx context.Context, levelName string) ([]blip.MetricValue
 			Name:  "response_time",
 			Value: value * 1000000, // convert seconds to microseconds for consistency with PFS quantiles
 			Meta: map[string]string{
-				metaKey(percentile): fmt.Sprintf("%.3f", actualPercentile),
+				MetaKey(percentile): fmt.Sprintf("%.3f", actualPercentile),
 			},
 		}
 		metrics = append(metrics, m)
 	}
 
+	if c.histogram[levelName] {
+		metrics = append(metrics, HistogramMetrics(buckets)...)
+	}
+
 	if c.flushQrt[levelName] {
 		_, err = c.db.Exec(flushQuery)
 		if err != nil {
@@ -212,6 +259,8 @@ func (c *QRT) prepareLevel(dom blip.Domain, level blip.Level) error {
 		c.flushQrt[level.Name] = true // default
 	}
 
+	c.histogram[level.Name] = dom.Options[OPT_HISTOGRAM] == "yes"
+
 	c.percentiles[level.Name] = map[float64]float64{}
 
 	var percentilesStr string
@@ -221,13 +270,28 @@ func (c *QRT) prepareLevel(dom blip.Domain, level blip.Level) error {
 		percentilesStr = default_percentile_option
 	}
 
-	percentilesList := strings.Split(strings.TrimSpace(percentilesStr), ",")
+	percentiles, err := ParsePercentiles(percentilesStr)
+	if err != nil {
+		return fmt.Errorf("%s: %s", level.Name, err)
+	}
+	for _, percentile := range percentiles {
+		c.percentiles[level.Name][percentile] = percentile
+	}
+	return nil
+}
+
+// ParsePercentiles parses a comma-separated list of percentiles formatted as
+// 999, 0.999, or 99.9 and returns each as a decimal: 0.999. Other collectors
+// that report percentiles use this function to accept the same option format.
+func ParsePercentiles(s string) ([]float64, error) {
+	percentilesList := strings.Split(strings.TrimSpace(s), ",")
+	percentiles := make([]float64, 0, len(percentilesList))
 
 	for _, percentileStr := range percentilesList {
 		percentileStr = strings.TrimSpace(percentileStr)
 		f, err := strconv.ParseFloat(percentileStr, 64)
 		if err != nil {
-			return fmt.Errorf("%s: could not parse percentile value in qrt collector %s into a number", level.Name, percentileStr)
+			return nil, fmt.Errorf("could not parse percentile value %s into a number", percentileStr)
 		}
 
 		var percentile float64
@@ -245,14 +309,45 @@ func (c *QRT) prepareLevel(dom blip.Domain, level blip.Level) error {
 			percentile = f / math.Pow10(len(percentileStr))
 		}
 
-		c.percentiles[level.Name][percentile] = percentile
+		percentiles = append(percentiles, percentile)
 	}
-	return nil
+	return percentiles, nil
+}
+
+// HistogramMetrics returns the QRT buckets as Prometheus-style histogram metrics:
+// response_time_bucket for each bucket (cumulative count, grouped by "le"),
+// response_time_count, and response_time_sum. Buckets must be sorted by time.
+func HistogramMetrics(buckets []QRTBucket) []blip.MetricValue {
+	metrics := make([]blip.MetricValue, 0, len(buckets)+2)
+	var count, sum float64
+	for _, b := range buckets {
+		count += float64(b.Count)
+		sum += b.Total
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "response_time_bucket",
+			Type:  blip.COUNTER,
+			Value: count,
+			Group: map[string]string{"le": strconv.FormatFloat(b.Time, 'f', -1, 64)},
+		})
+	}
+	metrics = append(metrics,
+		blip.MetricValue{
+			Name:  "response_time_count",
+			Type:  blip.COUNTER,
+			Value: count,
+		},
+		blip.MetricValue{
+			Name:  "response_time_sum",
+			Type:  blip.COUNTER,
+			Value: sum,
+		},
+	)
+	return metrics
 }
 
-// metaKey coverts a percentile into the form pNNN
+// MetaKey coverts a percentile into the form pNNN
 // where NNN is the requested percentile upto 1 decimal point
-func metaKey(f float64) string {
+func MetaKey(f float64) string {
 	percentile := f * 100
 	metaKey := fmt.Sprintf("%.1f", percentile)
 	metaKey = strings.Trim(metaKey, "0")
@@ -0,0 +1,224 @@
+// Copyright 2022 Block, Inc.
+
+package processlist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "processlist"
+
+	OPT_MIN_TIME = "min-time"
+
+	DEFAULT_MIN_TIME = "0"
+)
+
+type plMetrics struct {
+	threads bool
+	seconds bool
+	byUser  bool
+	byHost  bool
+}
+
+// Processlist collects metrics for the processlist domain. The source is
+// information_schema.processlist.
+type Processlist struct {
+	db *sql.DB
+	// --
+	atLevel map[string]plMetrics
+	query   map[string]string // keyed on level
+}
+
+var _ blip.Collector = &Processlist{}
+
+func NewProcesslist(db *sql.DB) *Processlist {
+	return &Processlist{
+		db:      db,
+		atLevel: map[string]plMetrics{},
+		query:   map[string]string{},
+	}
+}
+
+func (c *Processlist) Domain() string {
+	return DOMAIN
+}
+
+func (c *Processlist) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Threads (connections) by command, state, user, and host",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_MIN_TIME: {
+				Name:    OPT_MIN_TIME,
+				Desc:    "Minimum thread time (seconds) to be counted",
+				Default: DEFAULT_MIN_TIME,
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "command", Value: "COMMAND column (only metrics threads and seconds)"},
+			{Key: "state", Value: "STATE column (only metrics threads and seconds)"},
+			{Key: "user", Value: "USER column (only metric processes_by_user)"},
+			{Key: "host", Value: "HOST column without port (only metric processes_by_host)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "threads",
+				Type: blip.GAUGE,
+				Desc: "Number of threads by command and state",
+			},
+			{
+				Name: "seconds",
+				Type: blip.GAUGE,
+				Desc: "Total time of threads by command and state (seconds)",
+			},
+			{
+				Name: "processes_by_user",
+				Type: blip.GAUGE,
+				Desc: "Number of threads by user",
+			},
+			{
+				Name: "processes_by_host",
+				Type: blip.GAUGE,
+				Desc: "Number of threads by host",
+			},
+		},
+	}
+}
+
+// Prepare prepares queries for all levels in the plan that contain the processlist domain.
+func (c *Processlist) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		m := plMetrics{}
+		if len(dom.Metrics) == 0 {
+			m = plMetrics{threads: true, seconds: true, byUser: true, byHost: true}
+		}
+		for i := range dom.Metrics {
+			switch dom.Metrics[i] {
+			case "threads":
+				m.threads = true
+			case "seconds":
+				m.seconds = true
+			case "processes_by_user":
+				m.byUser = true
+			case "processes_by_host":
+				m.byHost = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
+			}
+		}
+		c.atLevel[level.Name] = m
+
+		minTime, err := strconv.ParseUint(blip.SetOrDefault(dom.Options[OPT_MIN_TIME], DEFAULT_MIN_TIME), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %s: must be an integer >= 0", OPT_MIN_TIME, dom.Options[OPT_MIN_TIME])
+		}
+		c.query[level.Name] = ProcesslistQuery(minTime)
+	}
+	return nil, nil
+}
+
+func (c *Processlist) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.query[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+	pm := c.atLevel[levelName]
+
+	rows, err := c.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type cmdState struct {
+		command, state string
+	}
+	threads := map[cmdState]float64{}
+	seconds := map[cmdState]float64{}
+	byUser := map[string]float64{}
+	byHost := map[string]float64{}
+
+	var (
+		user, host, command, state string
+		n, time                    float64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&user, &host, &command, &state, &n, &time); err != nil {
+			return nil, err
+		}
+		cs := cmdState{command, state}
+		threads[cs] += n
+		seconds[cs] += time
+		byUser[user] += n
+		byHost[host] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	metrics := []blip.MetricValue{}
+	for cs := range threads {
+		group := map[string]string{"command": cs.command, "state": cs.state}
+		if pm.threads {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "threads",
+				Type:  blip.GAUGE,
+				Value: threads[cs],
+				Group: group,
+			})
+		}
+		if pm.seconds {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "seconds",
+				Type:  blip.GAUGE,
+				Value: seconds[cs],
+				Group: group,
+			})
+		}
+	}
+	if pm.byUser {
+		for user, n := range byUser {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "processes_by_user",
+				Type:  blip.GAUGE,
+				Value: n,
+				Group: map[string]string{"user": user},
+			})
+		}
+	}
+	if pm.byHost {
+		for host, n := range byHost {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "processes_by_host",
+				Type:  blip.GAUGE,
+				Value: n,
+				Group: map[string]string{"host": host},
+			})
+		}
+	}
+
+	return metrics, nil
+}
+
+// ProcesslistQuery returns the information_schema.processlist query that selects
+// user, host (without port), command, state, number of threads, and total time,
+// in that order, for threads at least minTime seconds old. The thread running
+// the query is not counted.
+func ProcesslistQuery(minTime uint64) string {
+	return "SELECT user, SUBSTRING_INDEX(host, ':', 1), IFNULL(command, ''), IFNULL(state, ''), COUNT(*), IFNULL(SUM(time), 0)" +
+		" FROM information_schema.processlist" +
+		fmt.Sprintf(" WHERE id != CONNECTION_ID() AND time >= %d", minTime) +
+		" GROUP BY 1, 2, 3, 4"
+}
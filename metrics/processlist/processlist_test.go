@@ -0,0 +1,20 @@
+// Copyright 2022 Block, Inc.
+
+package processlist_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/processlist"
+)
+
+func TestProcesslistQuery(t *testing.T) {
+	got := processlist.ProcesslistQuery(5)
+	expect := "SELECT user, SUBSTRING_INDEX(host, ':', 1), IFNULL(command, ''), IFNULL(state, ''), COUNT(*), IFNULL(SUM(time), 0)" +
+		" FROM information_schema.processlist" +
+		" WHERE id != CONNECTION_ID() AND time >= 5" +
+		" GROUP BY 1, 2, 3, 4"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+}
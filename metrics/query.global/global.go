@@ -0,0 +1,297 @@
+// Copyright 2022 Block, Inc.
+
+package queryglobal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/percona"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+/*
+	mysql> SELECT * FROM performance_schema.events_statements_histogram_global WHERE count_bucket > 0 LIMIT 3;
+	+---------------+------------------+-------------------+--------------+------------------------+-----------------+
+	| BUCKET_NUMBER | BUCKET_TIMER_LOW | BUCKET_TIMER_HIGH | COUNT_BUCKET | COUNT_BUCKET_AND_LOWER | BUCKET_QUANTILE |
+	+---------------+------------------+-------------------+--------------+------------------------+-----------------+
+	|            36 |         10000000 |          10964781 |            2 |                      2 |        0.000068 |
+	|            37 |         10964781 |          12022644 |            9 |                     11 |        0.000376 |
+	|            38 |         12022644 |          13182567 |           31 |                     42 |        0.001436 |
+	+---------------+------------------+-------------------+--------------+------------------------+-----------------+
+
+	Timer values are picoseconds.
+*/
+
+const (
+	DOMAIN = "query.global"
+
+	OPT_PERCENTILES = "percentiles"
+	OPT_TRUNCATE    = "truncate"
+	OPT_BY_DIGEST   = "by-digest"
+
+	DEFAULT_PERCENTILES = "999"
+)
+
+const (
+	globalQuery = "SELECT bucket_timer_high, count_bucket" +
+		" FROM performance_schema.events_statements_histogram_global" +
+		" WHERE count_bucket > 0"
+
+	digestQuery = "SELECT IFNULL(schema_name, ''), IFNULL(digest, ''), bucket_timer_high, count_bucket" +
+		" FROM performance_schema.events_statements_histogram_by_digest" +
+		" WHERE count_bucket > 0 ORDER BY schema_name, digest"
+
+	truncateGlobal = "TRUNCATE TABLE performance_schema.events_statements_histogram_global"
+	truncateDigest = "TRUNCATE TABLE performance_schema.events_statements_histogram_by_digest"
+)
+
+type levelConfig struct {
+	percentiles []float64
+	truncate    bool
+	byDigest    bool
+}
+
+// Global collects query response time percentiles for the query.global domain.
+// The source is the MySQL 8.0 Performance Schema statement histograms, which
+// makes this the native equivalent of percona.response-time.
+type Global struct {
+	db *sql.DB
+	// --
+	atLevel map[string]levelConfig
+}
+
+var _ blip.Collector = &Global{}
+
+func NewGlobal(db *sql.DB) *Global {
+	return &Global{
+		db:      db,
+		atLevel: map[string]levelConfig{},
+	}
+}
+
+func (c *Global) Domain() string {
+	return DOMAIN
+}
+
+func (c *Global) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Query response time percentiles from MySQL 8.0 Performance Schema statement histograms",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_PERCENTILES: {
+				Name:    OPT_PERCENTILES,
+				Desc:    "Comma-separated list of percentiles formatted as 999, 0.999 or 99.9",
+				Default: DEFAULT_PERCENTILES,
+			},
+			OPT_TRUNCATE: {
+				Name:    OPT_TRUNCATE,
+				Desc:    "Truncate histogram tables after each collection",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Truncate histograms so each collection reports response time since the previous collection",
+					"no":  "Do not truncate histograms; report response time since MySQL started or histograms were last truncated",
+				},
+			},
+			OPT_BY_DIGEST: {
+				Name:    OPT_BY_DIGEST,
+				Desc:    "Also report response time per statement digest from events_statements_histogram_by_digest",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Report global and per-digest response time",
+					"no":  "Report only global response time",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "db", Value: "schema name (only if " + OPT_BY_DIGEST + "=yes)"},
+			{Key: "digest", Value: "statement digest (only if " + OPT_BY_DIGEST + "=yes)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "pN", Value: "Configured percentile N and actual percentile (value)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "response_time",
+				Type: blip.GAUGE,
+				Desc: "Response time percentile (microseconds)",
+			},
+		},
+	}
+}
+
+// Prepare prepares options for all levels in the plan that contain the query.global domain.
+func (c *Global) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	haveVersion := false
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		percentiles, err := percona.ParsePercentiles(blip.SetOrDefault(dom.Options[OPT_PERCENTILES], DEFAULT_PERCENTILES))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", level.Name, err)
+		}
+
+		c.atLevel[level.Name] = levelConfig{
+			percentiles: percentiles,
+			truncate:    dom.Options[OPT_TRUNCATE] == "yes",
+			byDigest:    dom.Options[OPT_BY_DIGEST] == "yes",
+		}
+
+		// Statement histograms are new in MySQL 8.0
+		if haveVersion {
+			continue
+		}
+		major, _, _ := sqlutil.MySQLVersion(ctx, c.db)
+		if major == -1 {
+			blip.Debug("failed to get/parse MySQL version, ignoring")
+			continue
+		}
+		haveVersion = true
+		if major < 8 {
+			return nil, fmt.Errorf("%s requires MySQL 8.0 or newer (use percona.response-time on Percona Server 5.7)", DOMAIN)
+		}
+	}
+	return nil, nil
+}
+
+func (c *Global) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	cfg, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+
+	metrics, err := c.collectGlobal(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.byDigest {
+		digestMetrics, err := c.collectDigest(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, digestMetrics...)
+	}
+
+	if cfg.truncate {
+		if _, err := c.db.ExecContext(ctx, truncateGlobal); err != nil {
+			return nil, err
+		}
+		if cfg.byDigest {
+			if _, err := c.db.ExecContext(ctx, truncateDigest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+func (c *Global) collectGlobal(ctx context.Context, cfg levelConfig) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, globalQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []percona.QRTBucket
+	var high float64
+	var count uint64
+	for rows.Next() {
+		if err := rows.Scan(&high, &count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, Bucket(high, count))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return Percentiles(buckets, cfg.percentiles, nil), nil
+}
+
+func (c *Global) collectDigest(ctx context.Context, cfg levelConfig) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, digestQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []blip.MetricValue
+	var buckets []percona.QRTBucket
+	var (
+		db, digest         string
+		lastDb, lastDigest string
+		high               float64
+		count              uint64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&db, &digest, &high, &count); err != nil {
+			return nil, err
+		}
+		// Rows are ordered by schema and digest, so a change in either means
+		// all buckets for the previous digest have been read
+		if len(buckets) > 0 && (db != lastDb || digest != lastDigest) {
+			metrics = append(metrics, Percentiles(buckets, cfg.percentiles, map[string]string{"db": lastDb, "digest": lastDigest})...)
+			buckets = nil
+		}
+		lastDb, lastDigest = db, digest
+		buckets = append(buckets, Bucket(high, count))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(buckets) > 0 {
+		metrics = append(metrics, Percentiles(buckets, cfg.percentiles, map[string]string{"db": lastDb, "digest": lastDigest})...)
+	}
+
+	return metrics, nil
+}
+
+// Bucket converts a Performance Schema histogram bucket to a percona.QRTBucket.
+// Performance Schema does not record total time per bucket, so the bucket upper
+// bound is used for every statement in the bucket, which makes the percentile
+// value the bucket upper bound. timerHigh is picoseconds.
+func Bucket(timerHigh float64, count uint64) percona.QRTBucket {
+	t := timerHigh / 1000000000000 // picoseconds to seconds
+	return percona.QRTBucket{
+		Time:  t,
+		Count: count,
+		Total: t * float64(count),
+	}
+}
+
+// Percentiles returns one response_time metric for each percentile. It returns
+// nil if there are no buckets or all buckets are empty.
+func Percentiles(buckets []percona.QRTBucket, percentiles []float64, group map[string]string) []blip.MetricValue {
+	h := percona.NewQRTHistogram(buckets)
+	var total uint64
+	for i := range buckets {
+		total += buckets[i].Count
+	}
+	if total == 0 {
+		return nil // no statements, no response time
+	}
+
+	metrics := make([]blip.MetricValue, 0, len(percentiles))
+	for _, p := range percentiles {
+		value, actualPercentile := h.Percentile(p)
+		metrics = append(metrics, blip.MetricValue{
+			Type:  blip.GAUGE,
+			Name:  "response_time",
+			Value: value * 1000000, // seconds to microseconds
+			Group: group,
+			Meta: map[string]string{
+				percona.MetaKey(p): fmt.Sprintf("%.3f", actualPercentile),
+			},
+		})
+	}
+	return metrics
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Block, Inc.
+
+package queryglobal_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/percona"
+	"github.com/cashapp/blip/metrics/query.global"
+)
+
+func TestPercentiles(t *testing.T) {
+	// bucket_timer_high (picoseconds), count_bucket
+	buckets := []percona.QRTBucket{
+		queryglobal.Bucket(10000000, 50),   // 10 us
+		queryglobal.Bucket(100000000, 40),  // 100 us
+		queryglobal.Bucket(1000000000, 9),  // 1 ms
+		queryglobal.Bucket(10000000000, 1), // 10 ms
+	}
+
+	percentiles := []float64{0.5, 0.9, 0.99, 0.999}
+	got := queryglobal.Percentiles(buckets, percentiles, nil)
+	expect := []struct {
+		value  float64
+		actual string
+	}{
+		{10, "0.500"},
+		{100, "0.900"},
+		{1000, "0.990"},
+		{10000, "1.000"},
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("got %d metrics, expected %d: %+v", len(got), len(expect), got)
+	}
+	for i := range expect {
+		if got[i].Name != "response_time" {
+			t.Errorf("metric %d: got name %s, expected response_time", i, got[i].Name)
+		}
+		if diff := got[i].Value - expect[i].value; diff > 0.000001 || diff < -0.000001 {
+			t.Errorf("metric %d: got value %f, expected %f", i, got[i].Value, expect[i].value)
+		}
+		key := percona.MetaKey(percentiles[i])
+		if got[i].Meta[key] != expect[i].actual {
+			t.Errorf("metric %d: got meta %v, expected %s=%s", i, got[i].Meta, key, expect[i].actual)
+		}
+	}
+
+	// No statements = no metrics
+	got = queryglobal.Percentiles(nil, []float64{0.999}, nil)
+	if len(got) != 0 {
+		t.Errorf("got %d metrics for empty histogram, expected 0: %+v", len(got), got)
+	}
+}
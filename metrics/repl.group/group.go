@@ -0,0 +1,231 @@
+// Copyright 2022 Block, Inc.
+
+package replgroup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "repl.group"
+)
+
+// Values for metric state
+const (
+	STATE_OFFLINE     = 0
+	STATE_ONLINE      = 1
+	STATE_RECOVERING  = 2
+	STATE_UNREACHABLE = 3
+	STATE_ERROR       = 4
+	STATE_UNKNOWN     = -1
+)
+
+var stateValue = map[string]float64{
+	"OFFLINE":     STATE_OFFLINE,
+	"ONLINE":      STATE_ONLINE,
+	"RECOVERING":  STATE_RECOVERING,
+	"UNREACHABLE": STATE_UNREACHABLE,
+	"ERROR":       STATE_ERROR,
+}
+
+const (
+	// MySQL 8.0 added member_role and count_transactions_remote_in_applier_queue
+	memberQuery = "SELECT CONCAT(m.member_host, ':', m.member_port), m.member_state, m.member_role," +
+		" IFNULL(s.count_transactions_in_queue, 0), IFNULL(s.count_transactions_checked, 0)," +
+		" IFNULL(s.count_conflicts_detected, 0), IFNULL(s.count_transactions_remote_in_applier_queue, 0)" +
+		" FROM performance_schema.replication_group_members m" +
+		" LEFT JOIN performance_schema.replication_group_member_stats s USING (member_id)" +
+		" WHERE m.member_host <> ''"
+
+	memberQuery57 = "SELECT CONCAT(m.member_host, ':', m.member_port), m.member_state, ''," +
+		" IFNULL(s.count_transactions_in_queue, 0), IFNULL(s.count_transactions_checked, 0)," +
+		" IFNULL(s.count_conflicts_detected, 0), 0" +
+		" FROM performance_schema.replication_group_members m" +
+		" LEFT JOIN performance_schema.replication_group_member_stats s USING (member_id)" +
+		" WHERE m.member_host <> ''"
+)
+
+// Group collects Group Replication (InnoDB Cluster) metrics for the repl.group
+// domain. The sources are performance_schema.replication_group_members and
+// performance_schema.replication_group_member_stats.
+type Group struct {
+	db *sql.DB
+	// --
+	atLevel map[string]bool // keyed on level
+	query   string
+}
+
+var _ blip.Collector = &Group{}
+
+func NewGroup(db *sql.DB) *Group {
+	return &Group{
+		db:      db,
+		atLevel: map[string]bool{},
+		query:   MemberQuery(8),
+	}
+}
+
+func (c *Group) Domain() string {
+	return DOMAIN
+}
+
+func (c *Group) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Group Replication member state and queues",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "member", Value: "member host:port"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "state", Value: "MEMBER_STATE (only metric state)"},
+			{Key: "role", Value: "MEMBER_ROLE (only metric primary, MySQL 8.0)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "state",
+				Type: blip.GAUGE,
+				Desc: fmt.Sprintf("Member state: %d=OFFLINE, %d=ONLINE, %d=RECOVERING, %d=UNREACHABLE, %d=ERROR, %d=unknown",
+					STATE_OFFLINE, STATE_ONLINE, STATE_RECOVERING, STATE_UNREACHABLE, STATE_ERROR, STATE_UNKNOWN),
+			},
+			{
+				Name: "primary",
+				Type: blip.GAUGE,
+				Desc: "1 if member is primary, else 0 (MySQL 8.0)",
+			},
+			{
+				Name: "trx_queue",
+				Type: blip.GAUGE,
+				Desc: "Transactions in queue pending conflict detection (COUNT_TRANSACTIONS_IN_QUEUE)",
+			},
+			{
+				Name: "trx_certified",
+				Type: blip.COUNTER,
+				Desc: "Transactions checked for conflicts (COUNT_TRANSACTIONS_CHECKED)",
+			},
+			{
+				Name: "trx_conflicted",
+				Type: blip.COUNTER,
+				Desc: "Transactions that failed conflict detection (COUNT_CONFLICTS_DETECTED)",
+			},
+			{
+				Name: "applier_queue",
+				Type: blip.GAUGE,
+				Desc: "Transactions received from the group waiting to be applied (COUNT_TRANSACTIONS_REMOTE_IN_APPLIER_QUEUE, MySQL 8.0)",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for all levels in the plan that contain the repl.group domain.
+func (c *Group) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	haveVersion := false
+
+LEVEL:
+	for _, level := range plan.Levels {
+		_, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+		c.atLevel[level.Name] = true
+
+		if haveVersion {
+			continue
+		}
+		major, _, _ := sqlutil.MySQLVersion(ctx, c.db)
+		if major == -1 {
+			blip.Debug("failed to get/parse MySQL version, ignoring")
+			continue
+		}
+		haveVersion = true
+		c.query = MemberQuery(major)
+	}
+	return nil, nil
+}
+
+func (c *Group) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	if !c.atLevel[levelName] {
+		return nil, nil // not collected in this level
+	}
+
+	rows, err := c.db.QueryContext(ctx, c.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []blip.MetricValue{}
+	for rows.Next() {
+		var m Member
+		if err = rows.Scan(&m.Member, &m.State, &m.Role, &m.Queue, &m.Checked, &m.Conflicts, &m.ApplierQueue); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, MemberMetrics(m)...)
+	}
+
+	return metrics, rows.Err()
+}
+
+// MemberQuery returns the query for the MySQL major version. The columns are
+// the fields of Member, in order.
+func MemberQuery(major int) string {
+	if major < 8 {
+		return memberQuery57
+	}
+	return memberQuery
+}
+
+// Member is one row from MemberQuery.
+type Member struct {
+	Member       string // host:port
+	State        string
+	Role         string // "" before MySQL 8.0
+	Queue        float64
+	Checked      float64
+	Conflicts    float64
+	ApplierQueue float64
+}
+
+// MemberMetrics returns the metrics for one member.
+func MemberMetrics(m Member) []blip.MetricValue {
+	group := map[string]string{"member": m.Member}
+
+	s, ok := stateValue[m.State]
+	if !ok {
+		s = STATE_UNKNOWN
+	}
+	metrics := []blip.MetricValue{
+		{
+			Name:  "state",
+			Type:  blip.GAUGE,
+			Value: s,
+			Group: group,
+			Meta:  map[string]string{"state": m.State},
+		},
+	}
+
+	if m.Role != "" { // MySQL 8.0
+		var primary float64
+		if m.Role == "PRIMARY" {
+			primary = 1
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "primary",
+			Type:  blip.GAUGE,
+			Value: primary,
+			Group: group,
+			Meta:  map[string]string{"role": m.Role},
+		})
+	}
+
+	return append(metrics,
+		blip.MetricValue{Name: "trx_queue", Type: blip.GAUGE, Value: m.Queue, Group: group},
+		blip.MetricValue{Name: "trx_certified", Type: blip.COUNTER, Value: m.Checked, Group: group},
+		blip.MetricValue{Name: "trx_conflicted", Type: blip.COUNTER, Value: m.Conflicts, Group: group},
+		blip.MetricValue{Name: "applier_queue", Type: blip.GAUGE, Value: m.ApplierQueue, Group: group},
+	)
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Block, Inc.
+
+package replgroup_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/repl.group"
+)
+
+func TestMemberQuery(t *testing.T) {
+	// MySQL 8.0 has member_role and count_transactions_remote_in_applier_queue
+	got := replgroup.MemberQuery(8)
+	for _, col := range []string{"m.member_role", "s.count_transactions_remote_in_applier_queue"} {
+		if !strings.Contains(got, col) {
+			t.Errorf("8.0 query does not contain %s: %s", col, got)
+		}
+	}
+
+	// MySQL 5.7 doesn't, but returns the same number of columns
+	got = replgroup.MemberQuery(5)
+	for _, col := range []string{"member_role", "count_transactions_remote_in_applier_queue"} {
+		if strings.Contains(got, col) {
+			t.Errorf("5.7 query contains %s: %s", col, got)
+		}
+	}
+	if !strings.HasPrefix(got, "SELECT CONCAT(m.member_host, ':', m.member_port), m.member_state, '',") {
+		t.Errorf("5.7 query does not select empty role: %s", got)
+	}
+}
+
+func TestMemberMetrics(t *testing.T) {
+	group := map[string]string{"member": "db1:3306"}
+
+	// MySQL 8.0 primary
+	got := replgroup.MemberMetrics(replgroup.Member{
+		Member:       "db1:3306",
+		State:        "ONLINE",
+		Role:         "PRIMARY",
+		Queue:        1,
+		Checked:      100,
+		Conflicts:    2,
+		ApplierQueue: 3,
+	})
+	expect := []blip.MetricValue{
+		{Name: "state", Type: blip.GAUGE, Value: replgroup.STATE_ONLINE, Group: group, Meta: map[string]string{"state": "ONLINE"}},
+		{Name: "primary", Type: blip.GAUGE, Value: 1, Group: group, Meta: map[string]string{"role": "PRIMARY"}},
+		{Name: "trx_queue", Type: blip.GAUGE, Value: 1, Group: group},
+		{Name: "trx_certified", Type: blip.COUNTER, Value: 100, Group: group},
+		{Name: "trx_conflicted", Type: blip.COUNTER, Value: 2, Group: group},
+		{Name: "applier_queue", Type: blip.GAUGE, Value: 3, Group: group},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// MySQL 5.7 (no role) and unknown state
+	got = replgroup.MemberMetrics(replgroup.Member{Member: "db1:3306", State: "FOO"})
+	if len(got) != 5 {
+		t.Fatalf("got %d metrics, expected 5 (no primary): %+v", len(got), got)
+	}
+	if got[0].Value != replgroup.STATE_UNKNOWN {
+		t.Errorf("state = %f, expected %d", got[0].Value, replgroup.STATE_UNKNOWN)
+	}
+	if got[1].Name != "trx_queue" {
+		t.Errorf("got metric %s, expected trx_queue", got[1].Name)
+	}
+}
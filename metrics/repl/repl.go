@@ -6,6 +6,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/sqlutil"
@@ -18,12 +20,24 @@ const (
 )
 
 type replMetrics struct {
-	chedkRunning bool
+	chedkRunning  bool
+	ioRunning     bool
+	sqlRunning    bool
+	secondsBehind bool
+	relayLogSpace bool
+	readLogPos    bool
+	execLogPos    bool
+	relayLogPos   bool
+	sqlDelay      bool
+	lastErrno     bool
+	lastIOErrno   bool
+	gtidGap       bool
 }
 
 type Repl struct {
 	db      *sql.DB
 	atLevel map[string]replMetrics
+	pfs     bool // MySQL 8.0: use performance_schema replication tables
 }
 
 var _ blip.Collector = &Repl{}
@@ -44,18 +58,91 @@ func (c *Repl) Help() blip.CollectorHelp {
 		Domain:      DOMAIN,
 		Description: "Replication status",
 		Options:     map[string]blip.CollectorHelpOption{},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "channel", Value: "replication channel name, or empty string for the default channel"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "source_host", Value: "Source_Host (Master_Host)"},
+			{Key: "source_uuid", Value: "Source_UUID (Master_UUID)"},
+		},
 		Metrics: []blip.CollectorMetric{
 			{
 				Name: "running",
 				Type: blip.GAUGE,
 				Desc: "1=running (no error), 0=not running, -1=not a replica",
 			},
+			{
+				Name: "io_running",
+				Type: blip.GAUGE,
+				Desc: "1=IO thread running, 0=not running",
+			},
+			{
+				Name: "sql_running",
+				Type: blip.GAUGE,
+				Desc: "1=SQL thread running, 0=not running",
+			},
+			{
+				Name: "seconds_behind",
+				Type: blip.GAUGE,
+				Desc: "Seconds_Behind_Source (not reported if NULL)",
+			},
+			{
+				Name: "relay_log_space",
+				Type: blip.GAUGE,
+				Desc: "Total size of all relay logs in bytes",
+			},
+			{
+				Name: "read_source_log_pos",
+				Type: blip.GAUGE,
+				Desc: "Read_Source_Log_Pos (Read_Master_Log_Pos): position in source binary log read by IO thread",
+			},
+			{
+				Name: "exec_source_log_pos",
+				Type: blip.GAUGE,
+				Desc: "Exec_Source_Log_Pos (Exec_Master_Log_Pos): position in source binary log executed by SQL thread",
+			},
+			{
+				Name: "relay_log_pos",
+				Type: blip.GAUGE,
+				Desc: "Relay_Log_Pos: position in relay log executed by SQL thread",
+			},
+			{
+				Name: "sql_delay",
+				Type: blip.GAUGE,
+				Desc: "SQL_Delay: seconds the replica is configured to lag the source",
+			},
+			{
+				Name: "last_errno",
+				Type: blip.GAUGE,
+				Desc: "Last SQL (applier) error number, 0=no error",
+			},
+			{
+				Name: "last_io_errno",
+				Type: blip.GAUGE,
+				Desc: "Last IO (receiver) error number, 0=no error",
+			},
+			{
+				Name: "gtid_gap",
+				Type: blip.GAUGE,
+				Desc: "Number of GTIDs received but not executed (MySQL 8.0)",
+			},
 		},
 	}
 }
 
 var statusQuery = "SHOW SLAVE STATUS" // SHOW REPLICA STATUS as of 8.022
 
+const (
+	// GTIDs received by each channel that have not been executed
+	gtidGapQuery = "SELECT channel_name, GTID_SUBTRACT(received_transaction_set, @@GLOBAL.gtid_executed)" +
+		" FROM performance_schema.replication_connection_status"
+
+	// With a multi-threaded replica, an applier error is reported by the
+	// worker, which SHOW REPLICA STATUS does not always reflect
+	workerErrnoQuery = "SELECT channel_name, MAX(last_error_number)" +
+		" FROM performance_schema.replication_applier_status_by_worker GROUP BY channel_name"
+)
+
 func (c *Repl) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
 	haveVersion := false
 
@@ -75,6 +162,28 @@ LEVEL:
 			switch dom.Metrics[i] {
 			case "running":
 				m.chedkRunning = true
+			case "io_running":
+				m.ioRunning = true
+			case "sql_running":
+				m.sqlRunning = true
+			case "seconds_behind":
+				m.secondsBehind = true
+			case "relay_log_space":
+				m.relayLogSpace = true
+			case "read_source_log_pos":
+				m.readLogPos = true
+			case "exec_source_log_pos":
+				m.execLogPos = true
+			case "relay_log_pos":
+				m.relayLogPos = true
+			case "sql_delay":
+				m.sqlDelay = true
+			case "last_errno":
+				m.lastErrno = true
+			case "last_io_errno":
+				m.lastIOErrno = true
+			case "gtid_gap":
+				m.gtidGap = true
 			default:
 				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
 			}
@@ -95,6 +204,7 @@ LEVEL:
 		if major == 8 && patch >= 22 {
 			statusQuery = "SHOW REPLICA STATUS"
 		}
+		c.pfs = major >= 8
 		blip.Debug("mysql %d.x.%d %s", major, patch, statusQuery)
 	}
 	return nil, nil
@@ -106,37 +216,251 @@ func (c *Repl) Collect(ctx context.Context, levelName string) ([]blip.MetricValu
 		return nil, nil
 	}
 
-	// Return SHOW SLAVE|REPLICA STATUS as map[string]string, which can be nil
-	// if MySQL is not a replica
-	replStatus, err := sqlutil.RowToMap(ctx, c.db, statusQuery)
+	// Return SHOW SLAVE|REPLICA STATUS as []map[string]string: one map per
+	// replication channel, or nil if MySQL is not a replica
+	replStatus, err := sqlutil.RowsToMaps(ctx, c.db, statusQuery)
 	if err != nil {
 		return nil, fmt.Errorf("%s failed: %s", statusQuery, err)
 	}
 
 	metrics := []blip.MetricValue{}
 
-	// Report repl.running: 1=running, 0=not running, -1=not a replica
-	//
-	// NOTE: values are literal, not passed through sqlutil.Float64, so
-	//       we look for "Yes" not 1, which works in this specific case.
-	if rm.chedkRunning {
-		var running float64 // 0 = not running by default
-		if len(replStatus) == 0 {
-			// no SHOW SLAVE|REPLICA STATUS output = not a replica
-			running = float64(NOT_A_REPLICA)
-		} else if replStatus["Slave_IO_Running"] == "Yes" && replStatus["Slave_SQL_Running"] == "Yes" && replStatus["Last_Errno"] == "0" {
-			// running if a replica and those ^ 3 conditions are true
-			running = 1
-		}
-		m := blip.MetricValue{
-			Name:  "running",
-			Type:  blip.GAUGE,
-			Value: running,
-		}
-		metrics = append(metrics, m)
+	if len(replStatus) == 0 {
+		// no SHOW SLAVE|REPLICA STATUS output = not a replica
+		if rm.chedkRunning {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "running",
+				Type:  blip.GAUGE,
+				Value: float64(NOT_A_REPLICA),
+			})
+		}
+		return metrics, nil
+	}
+
+	// MySQL 8.0: GTID gap and worker errors from performance_schema, keyed on channel
+	var gtidGap, workerErrno map[string]string
+	if c.pfs && rm.gtidGap {
+		if gtidGap, err = channelValues(ctx, c.db, gtidGapQuery); err != nil {
+			return nil, err
+		}
+	}
+	if c.pfs && (rm.lastErrno || rm.chedkRunning) {
+		if workerErrno, err = channelValues(ctx, c.db, workerErrnoQuery); err != nil {
+			return nil, err
+		}
 	}
 
-	// @todo collect other repl status metrics
+	// Group by channel only for multi-source replication (or one named channel).
+	// The usual single default channel is not grouped, so its metrics are the
+	// same series as before channels were reported.
+	grouped := len(replStatus) > 1 || replStatus[0]["Channel_Name"] != ""
+
+	for _, status := range replStatus {
+		channel := status["Channel_Name"] // "" for default channel and MySQL 5.6
+		var group map[string]string
+		if grouped {
+			group = map[string]string{"channel": channel}
+		}
+		meta := map[string]string{
+			"source_host": col(status, "Source_Host", "Master_Host"),
+			"source_uuid": col(status, "Source_UUID", "Master_UUID"),
+		}
+
+		// NOTE: values are literal, not passed through sqlutil.Float64, so
+		//       we look for "Yes" not 1, which works in this specific case.
+		ioRunning := col(status, "Replica_IO_Running", "Slave_IO_Running") == "Yes"
+		sqlRunning := col(status, "Replica_SQL_Running", "Slave_SQL_Running") == "Yes"
+		lastErrno, _ := sqlutil.Float64(status["Last_Errno"])
+		if n, ok := sqlutil.Float64(workerErrno[channel]); ok && n > lastErrno {
+			lastErrno = n
+		}
+
+		// Report repl.running: 1=running, 0=not running, -1=not a replica
+		if rm.chedkRunning {
+			var running float64 // 0 = not running by default
+			if ioRunning && sqlRunning && lastErrno == 0 {
+				// running if a replica and those ^ 3 conditions are true
+				running = 1
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "running",
+				Type:  blip.GAUGE,
+				Value: running,
+				Group: group,
+				Meta:  meta,
+			})
+		}
+
+		if rm.ioRunning {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "io_running",
+				Type:  blip.GAUGE,
+				Value: boolValue(ioRunning),
+				Group: group,
+				Meta:  meta,
+			})
+		}
+
+		if rm.sqlRunning {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "sql_running",
+				Type:  blip.GAUGE,
+				Value: boolValue(sqlRunning),
+				Group: group,
+				Meta:  meta,
+			})
+		}
+
+		if rm.secondsBehind {
+			// NULL (empty string) if SQL thread not running: don't report
+			if n, ok := sqlutil.Float64(col(status, "Seconds_Behind_Source", "Seconds_Behind_Master")); ok {
+				metrics = append(metrics, blip.MetricValue{
+					Name:  "seconds_behind",
+					Type:  blip.GAUGE,
+					Value: n,
+					Group: group,
+					Meta:  meta,
+				})
+			}
+		}
+
+		// Numeric columns reported as-is, if set
+		gauge := func(name string, cols ...string) {
+			if n, ok := sqlutil.Float64(col(status, cols...)); ok {
+				metrics = append(metrics, blip.MetricValue{
+					Name:  name,
+					Type:  blip.GAUGE,
+					Value: n,
+					Group: group,
+					Meta:  meta,
+				})
+			}
+		}
+		if rm.relayLogSpace {
+			gauge("relay_log_space", "Relay_Log_Space")
+		}
+		if rm.readLogPos {
+			gauge("read_source_log_pos", "Read_Source_Log_Pos", "Read_Master_Log_Pos")
+		}
+		if rm.execLogPos {
+			gauge("exec_source_log_pos", "Exec_Source_Log_Pos", "Exec_Master_Log_Pos")
+		}
+		if rm.relayLogPos {
+			gauge("relay_log_pos", "Relay_Log_Pos")
+		}
+		if rm.sqlDelay {
+			gauge("sql_delay", "SQL_Delay")
+		}
+
+		if rm.lastErrno {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "last_errno",
+				Type:  blip.GAUGE,
+				Value: lastErrno,
+				Group: group,
+				Meta:  meta,
+			})
+		}
+
+		if rm.lastIOErrno {
+			ioErrno, _ := sqlutil.Float64(status["Last_IO_Errno"])
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "last_io_errno",
+				Type:  blip.GAUGE,
+				Value: ioErrno,
+				Group: group,
+				Meta:  meta,
+			})
+		}
+
+		if rm.gtidGap && c.pfs {
+			gap, err := GTIDSetCount(gtidGap[channel])
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: %s", channel, err)
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "gtid_gap",
+				Type:  blip.GAUGE,
+				Value: gap,
+				Group: group,
+				Meta:  meta,
+			})
+		}
+	}
 
 	return metrics, nil
 }
+
+// col returns the value of the first column that exists. SHOW REPLICA STATUS
+// renamed several columns, like Slave_IO_Running to Replica_IO_Running.
+func col(status map[string]string, cols ...string) string {
+	for _, c := range cols {
+		if v, ok := status[c]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// channelValues returns a map of channel name => value from a query that
+// selects exactly those two columns.
+func channelValues(ctx context.Context, db *sql.DB, query string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vals := map[string]string{}
+	var channel string
+	var val sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&channel, &val); err != nil {
+			return nil, err
+		}
+		vals[channel] = val.String
+	}
+	return vals, rows.Err()
+}
+
+// GTIDSetCount returns the number of transactions in a GTID set like
+// "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11,4E11FA47-71CA-11E1-9E33-C80AA9429562:7".
+// Tags (MySQL 8.3 and newer) are ignored.
+func GTIDSetCount(set string) (float64, error) {
+	set = strings.NewReplacer("\n", "", "\r", "", " ", "").Replace(set)
+	if set == "" {
+		return 0, nil
+	}
+	var n float64
+	for _, uuidSet := range strings.Split(set, ",") {
+		parts := strings.Split(uuidSet, ":")
+		for _, interval := range parts[1:] {
+			if interval == "" || interval[0] < '0' || interval[0] > '9' {
+				continue // tag
+			}
+			bounds := strings.SplitN(interval, "-", 2)
+			start, err := strconv.ParseUint(bounds[0], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid GTID set %s: %s", set, err)
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseUint(bounds[1], 10, 64); err != nil {
+					return 0, fmt.Errorf("invalid GTID set %s: %s", set, err)
+				}
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid GTID set %s: interval %s end < start", set, interval)
+			}
+			n += float64(end - start + 1)
+		}
+	}
+	return n, nil
+}
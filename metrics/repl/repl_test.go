@@ -0,0 +1,37 @@
+// Copyright 2022 Block, Inc.
+
+package repl_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/repl"
+)
+
+func TestGTIDSetCount(t *testing.T) {
+	tests := []struct {
+		set    string
+		expect float64
+	}{
+		{"", 0},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:5", 1},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", 5},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11-12", 7},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,\n4e11fa47-71ca-11e1-9e33-c80aa9429562:7", 6},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2:tag1:3-4", 4},
+	}
+	for _, test := range tests {
+		got, err := repl.GTIDSetCount(test.set)
+		if err != nil {
+			t.Errorf("%q: error: %s", test.set, err)
+			continue
+		}
+		if got != test.expect {
+			t.Errorf("%q: got %f, expected %f", test.set, got, test.expect)
+		}
+	}
+
+	if _, err := repl.GTIDSetCount("3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1"); err == nil {
+		t.Error("no error for invalid interval, expected an error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	myerr "github.com/go-mysql/errors"
 
@@ -20,10 +21,17 @@ const (
 	OPT_NO_ACCESS  = "no-access"
 )
 
+type binlogMetrics struct {
+	bytes      bool
+	files      bool
+	fileNumber bool
+}
+
 // Binlog collects metrics for the size.binlog domain. The source is SHOW BINARY LOGS.
 type Binlog struct {
 	db *sql.DB
 	// --
+	atLevel           map[string]binlogMetrics
 	cols3             bool
 	noBinlogs         string
 	noBinlogsReported bool
@@ -37,6 +45,7 @@ func NewBinlog(db *sql.DB) *Binlog {
 	return &Binlog{
 		db: db,
 		// --
+		atLevel:   map[string]binlogMetrics{},
 		noBinlogs: "drop", // default value
 		noAccess:  "drop", // default value
 	}
@@ -78,11 +87,23 @@ func (c *Binlog) Help() blip.CollectorHelp {
 				Type: blip.GAUGE,
 				Desc: "Total size of all binary logs in bytes",
 			},
+			{
+				Name: "files",
+				Type: blip.GAUGE,
+				Desc: "Number of binary logs",
+			},
+			{
+				Name: "file_number",
+				Type: blip.GAUGE,
+				Desc: "Number (file name suffix) of the last binary log",
+			},
 		},
 	}
 }
 
 func (c *Binlog) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	prepared := false
+
 LEVEL:
 	for _, level := range plan.Levels {
 		dom, ok := level.Collect[DOMAIN]
@@ -90,6 +111,29 @@ LEVEL:
 			continue LEVEL // not collected at this level
 		}
 
+		// Only bytes by default for backwards-compatibility
+		m := binlogMetrics{bytes: len(dom.Metrics) == 0}
+		for i := range dom.Metrics {
+			switch dom.Metrics[i] {
+			case "bytes":
+				m.bytes = true
+			case "files":
+				m.files = true
+			case "file_number":
+				m.fileNumber = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
+			}
+		}
+		c.atLevel[level.Name] = m
+
+		// Only need to prepare the rest once because nothing changes: it's all
+		// just SHOW BINARY LOGS
+		if prepared {
+			continue LEVEL
+		}
+		prepared = true
+
 		// As of MySQL 8.0.14, SHOW BINARY LOGS has 3 cols instead of 2
 		if ok, _ := sqlutil.MySQLVersionGTE("8.0.14", c.db, ctx); ok {
 			c.cols3 = true
@@ -101,17 +145,18 @@ LEVEL:
 		if val, ok := dom.Options[OPT_NO_ACCESS]; ok {
 			c.noAccess = val
 		}
-
-		// Only need to prepare once because nothing changes: it's all just
-		// SHOW BINARY LOGS
-		break LEVEL
 	}
 	return nil, nil
 }
 
 func (c *Binlog) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	bm, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil // not collected at this level
+	}
+
 	// Total binlog size might be left a zero on error if option no-binlogs|no-access=zero
-	var total float64
+	var total, files, fileNumber float64
 
 	rows, err := c.db.QueryContext(ctx, "SHOW BINARY LOGS")
 	if err != nil {
@@ -167,6 +212,13 @@ func (c *Binlog) Collect(ctx context.Context, levelName string) ([]blip.MetricVa
 			if err != nil {
 				return nil, err
 			}
+			files++
+			// Binlog file name suffix is its number, like mysql-bin.000123
+			if i := strings.LastIndex(name, "."); i > -1 {
+				if n, ok = sqlutil.Float64(name[i+1:]); ok {
+					fileNumber = n
+				}
+			}
 			n, ok = sqlutil.Float64(val)
 			if !ok {
 				continue
@@ -175,11 +227,28 @@ func (c *Binlog) Collect(ctx context.Context, levelName string) ([]blip.MetricVa
 		}
 	}
 
-	metrics := []blip.MetricValue{{
-		Name:  "bytes",
-		Value: total,
-		Type:  blip.GAUGE,
-	}}
+	metrics := []blip.MetricValue{}
+	if bm.bytes {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "bytes",
+			Value: total,
+			Type:  blip.GAUGE,
+		})
+	}
+	if bm.files {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "files",
+			Value: files,
+			Type:  blip.GAUGE,
+		})
+	}
+	if bm.fileNumber {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "file_number",
+			Value: fileNumber,
+			Type:  blip.GAUGE,
+		})
+	}
 
 	return metrics, nil
 }
@@ -0,0 +1,57 @@
+// Copyright 2022 Block, Inc.
+
+package sizetable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const baseQuery = "SELECT table_schema, table_name, IFNULL(data_length, 0), IFNULL(index_length, 0), IFNULL(data_free, 0), IFNULL(table_rows, 0)" +
+	" FROM information_schema.tables"
+
+func TableSizeQuery(set map[string]string, def blip.CollectorHelp) (string, error) {
+	top := blip.SetOrDefault(set[OPT_TOP], def.Options[OPT_TOP].Default)
+	n, err := strconv.Atoi(top)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("invalid %s value: %s: must be an integer greater than zero", OPT_TOP, top)
+	}
+
+	like := false
+	if val := set[OPT_LIKE]; val == "yes" {
+		like = true
+	}
+
+	where := ""
+	if include := set[OPT_INCLUDE]; include != "" {
+		o := sqlutil.ObjectList(include, "'")
+		if like {
+			for i := range o {
+				o[i] = "table_schema LIKE " + o[i]
+			}
+			where = "(" + strings.Join(o, " OR ") + ")"
+		} else {
+			where = fmt.Sprintf("table_schema IN (%s)", strings.Join(o, ","))
+		}
+	} else {
+		exclude := set[OPT_EXCLUDE]
+		if exclude == "" {
+			exclude = def.Options[OPT_EXCLUDE].Default
+		}
+		o := sqlutil.ObjectList(exclude, "'")
+		if like {
+			for i := range o {
+				o[i] = "table_schema NOT LIKE " + o[i]
+			}
+			where = strings.Join(o, " AND ")
+		} else {
+			where = fmt.Sprintf("table_schema NOT IN (%s)", strings.Join(o, ","))
+		}
+	}
+
+	return fmt.Sprintf("%s WHERE table_type = 'BASE TABLE' AND %s ORDER BY data_length+index_length DESC LIMIT %d", baseQuery, where, n), nil
+}
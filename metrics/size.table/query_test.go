@@ -0,0 +1,78 @@
+// Copyright 2022 Block, Inc.
+
+package sizetable_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/size.table"
+)
+
+const base = "SELECT table_schema, table_name, IFNULL(data_length, 0), IFNULL(index_length, 0), IFNULL(data_free, 0), IFNULL(table_rows, 0)" +
+	" FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND "
+
+func TestTableSizeQuery(t *testing.T) {
+	tableSize := sizetable.NewTable(nil)
+
+	// All defaults
+	opts := map[string]string{}
+	got, err := sizetable.TableSizeQuery(opts, tableSize.Help())
+	expect := base + "table_schema NOT IN ('mysql','information_schema','performance_schema','sys') ORDER BY data_length+index_length DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// Include overrides exclude, top 5
+	opts = map[string]string{
+		sizetable.OPT_INCLUDE: "foo,bar",
+		sizetable.OPT_EXCLUDE: "a,b,c", // ignored
+		sizetable.OPT_TOP:     "5",
+	}
+	got, err = sizetable.TableSizeQuery(opts, tableSize.Help())
+	expect = base + "table_schema IN ('foo','bar') ORDER BY data_length+index_length DESC LIMIT 5"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// LIKE include
+	opts = map[string]string{
+		sizetable.OPT_LIKE:    "yes",
+		sizetable.OPT_INCLUDE: "foo%,bar",
+	}
+	got, err = sizetable.TableSizeQuery(opts, tableSize.Help())
+	expect = base + "(table_schema LIKE 'foo%' OR table_schema LIKE 'bar') ORDER BY data_length+index_length DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// LIKE exclude
+	opts = map[string]string{
+		sizetable.OPT_LIKE:    "yes",
+		sizetable.OPT_EXCLUDE: "x,y",
+	}
+	got, err = sizetable.TableSizeQuery(opts, tableSize.Help())
+	expect = base + "table_schema NOT LIKE 'x' AND table_schema NOT LIKE 'y' ORDER BY data_length+index_length DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// Invalid top
+	opts = map[string]string{
+		sizetable.OPT_TOP: "all",
+	}
+	if _, err = sizetable.TableSizeQuery(opts, tableSize.Help()); err == nil {
+		t.Error("no error for top=all, expected an error")
+	}
+}
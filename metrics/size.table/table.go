@@ -0,0 +1,154 @@
+// Copyright 2022 Block, Inc.
+
+package sizetable
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "size.table"
+
+	OPT_INCLUDE = "include"
+	OPT_EXCLUDE = "exclude"
+	OPT_LIKE    = "like"
+	OPT_TOP     = "top"
+
+	DEFAULT_TOP = "100"
+)
+
+// Table collects table sizes for domain size.table.
+type Table struct {
+	db *sql.DB
+	// --
+	query map[string]string // keyed on level
+}
+
+var _ blip.Collector = &Table{}
+
+func NewTable(db *sql.DB) *Table {
+	return &Table{
+		db:    db,
+		query: map[string]string{},
+	}
+}
+
+func (c *Table) Domain() string {
+	return DOMAIN
+}
+
+func (c *Table) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Table sizes and approximate row counts",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separate list of database names to include (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name:    OPT_EXCLUDE,
+				Desc:    "Comma-separate list of database names to exclude (ignored if " + OPT_INCLUDE + " set)",
+				Default: "mysql,information_schema,performance_schema,sys",
+			},
+			OPT_LIKE: {
+				Name:    OPT_LIKE,
+				Desc:    fmt.Sprintf("Each database in %s or %s is a MySQL LIKE pattern", OPT_INCLUDE, OPT_EXCLUDE),
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Enable, use LIKE pattern matching",
+					"no":  "Disable, use literal database names",
+				},
+			},
+			OPT_TOP: {
+				Name:    OPT_TOP,
+				Desc:    "Report only the top N largest tables (data + index size)",
+				Default: DEFAULT_TOP,
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "db", Value: "database name"},
+			{Key: "tbl", Value: "table name"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "data_bytes",
+				Type: blip.GAUGE,
+				Desc: "Data size (DATA_LENGTH)",
+			},
+			{
+				Name: "index_bytes",
+				Type: blip.GAUGE,
+				Desc: "Index size (INDEX_LENGTH)",
+			},
+			{
+				Name: "free_bytes",
+				Type: blip.GAUGE,
+				Desc: "Allocated but unused space (DATA_FREE)",
+			},
+			{
+				Name: "rows",
+				Type: blip.GAUGE,
+				Desc: "Approximate number of rows (TABLE_ROWS)",
+			},
+		},
+	}
+}
+
+// Prepare prepares queries for all levels in the plan that contain the size.table domain.
+func (c *Table) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+		q, err := TableSizeQuery(dom.Options, c.Help())
+		if err != nil {
+			return nil, err
+		}
+		c.query[level.Name] = q
+	}
+	return nil, nil
+}
+
+func (c *Table) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.query[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+
+	rows, err := c.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []blip.MetricValue{}
+
+	var (
+		db, tbl                  string
+		data, index, free, nRows float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&db, &tbl, &data, &index, &free, &nRows); err != nil {
+			return nil, err
+		}
+		group := map[string]string{
+			"db":  db,
+			"tbl": tbl,
+		}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "data_bytes", Type: blip.GAUGE, Value: data, Group: group},
+			blip.MetricValue{Name: "index_bytes", Type: blip.GAUGE, Value: index, Group: group},
+			blip.MetricValue{Name: "free_bytes", Type: blip.GAUGE, Value: free, Group: group},
+			blip.MetricValue{Name: "rows", Type: blip.GAUGE, Value: nRows, Group: group},
+		)
+	}
+
+	return metrics, rows.Err()
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 Block, Inc.
+
+package sqlcustom
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "sql.custom"
+
+	OPT_QUERY   = "query"
+	OPT_METRICS = "metrics"
+	OPT_GROUP   = "group"
+	OPT_TIMEOUT = "timeout"
+
+	DEFAULT_TIMEOUT = "2s"
+)
+
+// Metric maps a query column to a Blip metric.
+type Metric struct {
+	Column string
+	Name   string
+	Type   byte
+}
+
+type query struct {
+	query   string
+	metrics []Metric
+	group   []string
+	timeout time.Duration
+}
+
+// Custom collects metrics for the sql.custom domain. The source is a query
+// given in the plan.
+type Custom struct {
+	db *sql.DB
+	// --
+	atLevel map[string]query // keyed on level
+}
+
+var _ blip.Collector = &Custom{}
+var _ blip.OptionValidator = &Custom{}
+
+func NewCustom(db *sql.DB) *Custom {
+	return &Custom{
+		db:      db,
+		atLevel: map[string]query{},
+	}
+}
+
+func (c *Custom) Domain() string {
+	return DOMAIN
+}
+
+func (c *Custom) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Metrics from a custom SELECT query given in the plan, like queue depth or job backlog",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_QUERY: {
+				Name: OPT_QUERY,
+				Desc: "SELECT query (required; read-only, one statement)",
+			},
+			OPT_METRICS: {
+				Name: OPT_METRICS,
+				Desc: "Comma-separated list of column:type or column:name:type where type is counter or gauge (required)",
+			},
+			OPT_GROUP: {
+				Name: OPT_GROUP,
+				Desc: "Comma-separated list of columns to use as group keys",
+			},
+			OPT_TIMEOUT: {
+				Name:    OPT_TIMEOUT,
+				Desc:    "Query timeout (Go duration string)",
+				Default: DEFAULT_TIMEOUT,
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "(column)", Value: "each column in option " + OPT_GROUP},
+		},
+	}
+}
+
+// ValidateOptions validates the query, metrics, group, and timeout options.
+// It is called by the plan loader; see blip.OptionValidator.
+func (c *Custom) ValidateOptions(opts map[string]string) error {
+	_, err := parseOptions(opts)
+	return err
+}
+
+// Prepare prepares the query for all levels in the plan that contain the sql.custom domain.
+func (c *Custom) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+		q, err := parseOptions(dom.Options)
+		if err != nil {
+			return nil, err
+		}
+		c.atLevel[level.Name] = q
+	}
+	return nil, nil
+}
+
+func (c *Custom) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	// Run query in a read-only transaction so MySQL enforces that it's read-only,
+	// in addition to the static checks in ValidateQuery
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, q.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Map column name => index in row
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colIndex := map[string]int{}
+	for i, col := range columns {
+		colIndex[strings.ToLower(col)] = i
+	}
+	metricCols := make([]int, len(q.metrics))
+	for i, m := range q.metrics {
+		n, ok := colIndex[m.Column]
+		if !ok {
+			return nil, fmt.Errorf("metric column %s not returned by query", m.Column)
+		}
+		metricCols[i] = n
+	}
+	groupCols := make([]int, len(q.group))
+	for i, g := range q.group {
+		n, ok := colIndex[g]
+		if !ok {
+			return nil, fmt.Errorf("group column %s not returned by query", g)
+		}
+		groupCols[i] = n
+	}
+
+	scanArgs := make([]interface{}, len(columns))
+	values := make([]sql.RawBytes, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	metrics := []blip.MetricValue{}
+	for rows.Next() {
+		if err = rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+
+		var group map[string]string
+		if len(groupCols) > 0 {
+			group = make(map[string]string, len(groupCols))
+			for i, n := range groupCols {
+				group[q.group[i]] = string(values[n])
+			}
+		}
+
+		for i, m := range q.metrics {
+			val, ok := sqlutil.Float64(string(values[metricCols[i]]))
+			if !ok {
+				continue // NULL or not a number
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  m.Name,
+				Type:  m.Type,
+				Value: val,
+				Group: group,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+// --------------------------------------------------------------------------
+
+const namePattern = `^[a-zA-Z0-9_]+$`
+
+var validName = regexp.MustCompile(namePattern)
+
+func parseOptions(opts map[string]string) (query, error) {
+	q := query{}
+
+	if err := ValidateQuery(opts[OPT_QUERY]); err != nil {
+		return q, err
+	}
+	q.query = strings.TrimRight(strings.TrimSpace(opts[OPT_QUERY]), "; \t\n")
+
+	metrics, err := ParseMetrics(opts[OPT_METRICS])
+	if err != nil {
+		return q, err
+	}
+	q.metrics = metrics
+
+	if groups := opts[OPT_GROUP]; groups != "" {
+		for _, g := range strings.Split(groups, ",") {
+			g = strings.ToLower(strings.TrimSpace(g))
+			if !validName.MatchString(g) {
+				return q, fmt.Errorf("invalid %s column: %s (does not match /%s/)", OPT_GROUP, g, namePattern)
+			}
+			q.group = append(q.group, g)
+		}
+	}
+
+	timeout := blip.SetOrDefault(opts[OPT_TIMEOUT], DEFAULT_TIMEOUT)
+	q.timeout, err = time.ParseDuration(timeout)
+	if err != nil {
+		return q, fmt.Errorf("invalid %s: %s: %s", OPT_TIMEOUT, timeout, err)
+	}
+	if q.timeout <= 0 {
+		return q, fmt.Errorf("invalid %s: %s: must be greater than zero", OPT_TIMEOUT, timeout)
+	}
+
+	return q, nil
+}
+
+// ValidateQuery returns an error if the query is not a single SELECT statement.
+// This is only a static check to catch mistakes; Collect also runs the query in
+// a read-only transaction.
+func ValidateQuery(q string) error {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return fmt.Errorf("option %s is required", OPT_QUERY)
+	}
+	upper := strings.ToUpper(q)
+	if !strings.HasPrefix(upper, "SELECT") && !strings.HasPrefix(upper, "WITH") {
+		return fmt.Errorf("invalid %s: must be a SELECT statement", OPT_QUERY)
+	}
+	if strings.Contains(strings.TrimRight(q, "; \t\n"), ";") {
+		return fmt.Errorf("invalid %s: must be a single statement", OPT_QUERY)
+	}
+	upper = strings.Join(strings.Fields(upper), " ") // normalize whitespace
+	for _, kw := range []string{" INTO ", " FOR UPDATE", " FOR SHARE", " LOCK IN SHARE MODE"} {
+		if strings.Contains(upper, kw) {
+			return fmt.Errorf("invalid %s: %s not allowed", OPT_QUERY, strings.TrimSpace(kw))
+		}
+	}
+	return nil
+}
+
+// ParseMetrics parses option metrics: a comma-separated list of column:type
+// or column:name:type. Column names are case-insensitive.
+func ParseMetrics(s string) ([]Metric, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("option %s is required", OPT_METRICS)
+	}
+	var metrics []Metric
+	for _, v := range strings.Split(s, ",") {
+		f := strings.Split(strings.TrimSpace(v), ":")
+		var m Metric
+		var mtype string
+		switch len(f) {
+		case 2:
+			m.Column, m.Name, mtype = f[0], f[0], f[1]
+		case 3:
+			m.Column, m.Name, mtype = f[0], f[1], f[2]
+		default:
+			return nil, fmt.Errorf("invalid %s value: %s: expected column:type or column:name:type", OPT_METRICS, v)
+		}
+		m.Column = strings.ToLower(m.Column)
+		if !validName.MatchString(m.Column) {
+			return nil, fmt.Errorf("invalid %s column: %s (does not match /%s/)", OPT_METRICS, m.Column, namePattern)
+		}
+		if !validName.MatchString(m.Name) {
+			return nil, fmt.Errorf("invalid %s name: %s (does not match /%s/)", OPT_METRICS, m.Name, namePattern)
+		}
+		switch strings.ToLower(mtype) {
+		case "counter":
+			m.Type = blip.COUNTER
+		case "gauge":
+			m.Type = blip.GAUGE
+		default:
+			return nil, fmt.Errorf("invalid %s type: %s: expected counter or gauge", OPT_METRICS, mtype)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Block, Inc.
+
+package sqlcustom_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/sql.custom"
+)
+
+func TestValidateQuery(t *testing.T) {
+	valid := []string{
+		"SELECT COUNT(*) AS n FROM app.jobs",
+		"select n from app.queue;",
+		"WITH t AS (SELECT 1 AS n) SELECT n FROM t",
+	}
+	for _, q := range valid {
+		if err := sqlcustom.ValidateQuery(q); err != nil {
+			t.Errorf("%s: got error %s, expected nil", q, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"DELETE FROM app.jobs",
+		"SELECT 1; DROP TABLE app.jobs",
+		"SELECT n INTO @n FROM app.queue",
+		"SELECT n FROM app.queue FOR UPDATE",
+		"SELECT n FROM app.queue\nLOCK  IN SHARE MODE",
+	}
+	for _, q := range invalid {
+		if err := sqlcustom.ValidateQuery(q); err == nil {
+			t.Errorf("%q: no error, expected an error", q)
+		}
+	}
+}
+
+func TestParseMetrics(t *testing.T) {
+	got, err := sqlcustom.ParseMetrics("n:gauge, Done:jobs_done:counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []sqlcustom.Metric{
+		{Column: "n", Name: "n", Type: blip.GAUGE},
+		{Column: "done", Name: "jobs_done", Type: blip.COUNTER},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	invalid := []string{
+		"",
+		"n",
+		"n:histogram",
+		"n:foo bar:gauge",
+		"a:b:c:gauge",
+	}
+	for _, s := range invalid {
+		if _, err := sqlcustom.ParseMetrics(s); err == nil {
+			t.Errorf("%q: no error, expected an error", s)
+		}
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	c := sqlcustom.NewCustom(nil)
+
+	opts := map[string]string{
+		sqlcustom.OPT_QUERY:   "SELECT queue, COUNT(*) AS n FROM app.jobs GROUP BY queue",
+		sqlcustom.OPT_METRICS: "n:depth:gauge",
+		sqlcustom.OPT_GROUP:   "queue",
+	}
+	if err := c.ValidateOptions(opts); err != nil {
+		t.Errorf("got error %s, expected nil", err)
+	}
+
+	opts[sqlcustom.OPT_TIMEOUT] = "500"
+	if err := c.ValidateOptions(opts); err == nil {
+		t.Error("no error for timeout without unit, expected an error")
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 Block, Inc.
+
+package stmtdigest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "stmt.digest"
+
+	OPT_TOP      = "top"
+	OPT_ORDER_BY = "order-by"
+	OPT_INCLUDE  = "include"
+	OPT_EXCLUDE  = "exclude"
+	OPT_LIKE     = "like"
+
+	ORDER_BY_LATENCY = "latency"
+	ORDER_BY_COUNT   = "count"
+
+	DEFAULT_TOP = "100"
+)
+
+/*
+	mysql> SELECT * FROM performance_schema.events_statements_summary_by_digest LIMIT 1\G
+	*************************** 1. row ***************************
+	                SCHEMA_NAME: app
+	                     DIGEST: 2b6c1c0bd6da8c3d8e2b3e8c7a3bd5f8d1b0b6ea4c0c4e9f0ea2d0b5d2c5a8b1
+	                DIGEST_TEXT: SELECT `c` FROM `t` WHERE `id` = ?
+	                 COUNT_STAR: 1523
+	             SUM_TIMER_WAIT: 853249000000
+	             AVG_TIMER_WAIT: 560242000
+	          SUM_ROWS_EXAMINED: 1523
+	              SUM_ROWS_SENT: 1523
+	SUM_CREATED_TMP_DISK_TABLES: 0
+	          SUM_NO_INDEX_USED: 0
+	...
+*/
+
+// Digest collects metrics for the stmt.digest domain. The source is
+// performance_schema.events_statements_summary_by_digest.
+type Digest struct {
+	db *sql.DB
+	// --
+	query map[string]string          // keyed on level
+	keep  map[string]map[string]bool // keyed on level => metric name (nil = all)
+}
+
+var _ blip.Collector = &Digest{}
+
+func NewDigest(db *sql.DB) *Digest {
+	return &Digest{
+		db:    db,
+		query: map[string]string{},
+		keep:  map[string]map[string]bool{},
+	}
+}
+
+func (c *Digest) Domain() string {
+	return DOMAIN
+}
+
+func (c *Digest) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Statement digest metrics like 'exec_count' and 'latency_total' from performance_schema",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_TOP: {
+				Name:    OPT_TOP,
+				Desc:    "Report only the top N digests (ordered by option " + OPT_ORDER_BY + ")",
+				Default: DEFAULT_TOP,
+			},
+			OPT_ORDER_BY: {
+				Name:    OPT_ORDER_BY,
+				Desc:    "How to order digests to determine the top N",
+				Default: ORDER_BY_LATENCY,
+				Values: map[string]string{
+					ORDER_BY_LATENCY: "Total latency (SUM_TIMER_WAIT)",
+					ORDER_BY_COUNT:   "Execution count (COUNT_STAR)",
+				},
+			},
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separate list of schema names to include (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name:    OPT_EXCLUDE,
+				Desc:    "Comma-separate list of schema names to exclude (ignored if " + OPT_INCLUDE + " set)",
+				Default: "mysql,information_schema,performance_schema,sys",
+			},
+			OPT_LIKE: {
+				Name:    OPT_LIKE,
+				Desc:    fmt.Sprintf("Each schema in %s or %s is a MySQL LIKE pattern", OPT_INCLUDE, OPT_EXCLUDE),
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Enable, use LIKE pattern matching",
+					"no":  "Disable, use literal schema names",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "db", Value: "schema name, or empty string if no default database"},
+			{Key: "digest", Value: "statement digest (SHA-256)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "exec_count",
+				Type: blip.COUNTER,
+				Desc: "Number of times the statement was executed",
+			},
+			{
+				Name: "latency_total",
+				Type: blip.COUNTER,
+				Desc: "Total execution time (microseconds)",
+			},
+			{
+				Name: "latency_avg",
+				Type: blip.GAUGE,
+				Desc: "Average execution time (microseconds)",
+			},
+			{
+				Name: "rows_examined",
+				Type: blip.COUNTER,
+				Desc: "Number of rows examined",
+			},
+			{
+				Name: "rows_sent",
+				Type: blip.COUNTER,
+				Desc: "Number of rows sent",
+			},
+			{
+				Name: "tmp_disk_tables",
+				Type: blip.COUNTER,
+				Desc: "Number of on-disk temporary tables created",
+			},
+			{
+				Name: "no_index_used",
+				Type: blip.COUNTER,
+				Desc: "Number of executions that did not use an index",
+			},
+		},
+	}
+}
+
+// Prepare prepares queries for all levels in the plan that contain the stmt.digest domain.
+func (c *Digest) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	help := c.Help()
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		q, err := DigestQuery(dom.Options, help)
+		if err != nil {
+			return nil, err
+		}
+		c.query[level.Name] = q
+
+		// Collect (keep) only the given metrics, if any; else, collect all
+		if len(dom.Metrics) > 0 {
+			valid := map[string]bool{}
+			for _, m := range help.Metrics {
+				valid[m.Name] = true
+			}
+			keep := make(map[string]bool, len(dom.Metrics))
+			for i := range dom.Metrics {
+				name := strings.ToLower(dom.Metrics[i])
+				if !valid[name] {
+					return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
+				}
+				keep[name] = true
+			}
+			c.keep[level.Name] = keep
+		}
+	}
+	return nil, nil
+}
+
+func (c *Digest) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.query[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+
+	rows, err := c.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keep := c.keep[levelName] // nil = all metrics
+	metrics := []blip.MetricValue{}
+
+	var (
+		db     string
+		digest string
+		vals   [7]float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&db, &digest, &vals[0], &vals[1], &vals[2], &vals[3], &vals[4], &vals[5], &vals[6])
+		if err != nil {
+			return nil, err
+		}
+		for i := range columns {
+			if keep != nil && !keep[columns[i].name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  columns[i].name,
+				Type:  columns[i].mtype,
+				Value: vals[i],
+				Group: map[string]string{
+					"db":     db,
+					"digest": digest,
+				},
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+// columns maps the value columns selected by DigestQuery, in order, to Blip
+// metrics. Timer columns are picoseconds in performance_schema, so the query
+// converts them to microseconds.
+var columns = []struct {
+	name  string
+	mtype byte
+}{
+	{"exec_count", blip.COUNTER},
+	{"latency_total", blip.COUNTER},
+	{"latency_avg", blip.GAUGE},
+	{"rows_examined", blip.COUNTER},
+	{"rows_sent", blip.COUNTER},
+	{"tmp_disk_tables", blip.COUNTER},
+	{"no_index_used", blip.COUNTER},
+}
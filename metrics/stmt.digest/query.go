@@ -0,0 +1,74 @@
+// Copyright 2022 Block, Inc.
+
+package stmtdigest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const baseQuery = "SELECT IFNULL(schema_name, ''), IFNULL(digest, ''), count_star, sum_timer_wait/1000000, avg_timer_wait/1000000," +
+	" sum_rows_examined, sum_rows_sent, sum_created_tmp_disk_tables, sum_no_index_used" +
+	" FROM performance_schema.events_statements_summary_by_digest"
+
+func DigestQuery(set map[string]string, def blip.CollectorHelp) (string, error) {
+	top := set[OPT_TOP]
+	if top == "" {
+		top = def.Options[OPT_TOP].Default
+	}
+	n, err := strconv.Atoi(top)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("invalid %s value: %s: must be an integer greater than zero", OPT_TOP, top)
+	}
+
+	var orderBy string
+	switch set[OPT_ORDER_BY] {
+	case "", ORDER_BY_LATENCY:
+		orderBy = "sum_timer_wait"
+	case ORDER_BY_COUNT:
+		orderBy = "count_star"
+	default:
+		return "", fmt.Errorf("invalid %s value: %s; valid values: %s, %s", OPT_ORDER_BY, set[OPT_ORDER_BY], ORDER_BY_LATENCY, ORDER_BY_COUNT)
+	}
+
+	like := false
+	if val := set[OPT_LIKE]; val == "yes" {
+		like = true
+	}
+
+	// Statements executed without a default database have a NULL schema_name,
+	// which is reported as an empty string and excluded only by include
+	const col = "IFNULL(schema_name, '')"
+	where := ""
+	if include := set[OPT_INCLUDE]; include != "" {
+		o := sqlutil.ObjectList(include, "'")
+		if like {
+			for i := range o {
+				o[i] = col + " LIKE " + o[i]
+			}
+			where = strings.Join(o, " OR ")
+		} else {
+			where = fmt.Sprintf("%s IN (%s)", col, strings.Join(o, ","))
+		}
+	} else {
+		exclude := set[OPT_EXCLUDE]
+		if exclude == "" {
+			exclude = def.Options[OPT_EXCLUDE].Default
+		}
+		o := sqlutil.ObjectList(exclude, "'")
+		if like {
+			for i := range o {
+				o[i] = col + " NOT LIKE " + o[i]
+			}
+			where = strings.Join(o, " AND ")
+		} else {
+			where = fmt.Sprintf("%s NOT IN (%s)", col, strings.Join(o, ","))
+		}
+	}
+
+	return fmt.Sprintf("%s WHERE %s ORDER BY %s DESC LIMIT %d", baseQuery, where, orderBy, n), nil
+}
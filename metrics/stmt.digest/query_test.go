@@ -0,0 +1,78 @@
+// Copyright 2022 Block, Inc.
+
+package stmtdigest_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/stmt.digest"
+)
+
+const base = "SELECT IFNULL(schema_name, ''), IFNULL(digest, ''), count_star, sum_timer_wait/1000000, avg_timer_wait/1000000," +
+	" sum_rows_examined, sum_rows_sent, sum_created_tmp_disk_tables, sum_no_index_used" +
+	" FROM performance_schema.events_statements_summary_by_digest"
+
+func TestDigestQuery(t *testing.T) {
+	digest := stmtdigest.NewDigest(nil)
+
+	// All defaults
+	opts := map[string]string{}
+	got, err := stmtdigest.DigestQuery(opts, digest.Help())
+	expect := base + " WHERE IFNULL(schema_name, '') NOT IN ('mysql','information_schema','performance_schema','sys') ORDER BY sum_timer_wait DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// Top 10 by count
+	opts = map[string]string{
+		stmtdigest.OPT_TOP:      "10",
+		stmtdigest.OPT_ORDER_BY: "count",
+	}
+	got, err = stmtdigest.DigestQuery(opts, digest.Help())
+	expect = base + " WHERE IFNULL(schema_name, '') NOT IN ('mysql','information_schema','performance_schema','sys') ORDER BY count_star DESC LIMIT 10"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// Include overrides exclude
+	opts = map[string]string{
+		stmtdigest.OPT_INCLUDE: "foo,bar",
+		stmtdigest.OPT_EXCLUDE: "a,b,c", // ignored
+	}
+	got, err = stmtdigest.DigestQuery(opts, digest.Help())
+	expect = base + " WHERE IFNULL(schema_name, '') IN ('foo','bar') ORDER BY sum_timer_wait DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// LIKE exclude
+	opts = map[string]string{
+		stmtdigest.OPT_LIKE:    "yes",
+		stmtdigest.OPT_EXCLUDE: "x%,y",
+	}
+	got, err = stmtdigest.DigestQuery(opts, digest.Help())
+	expect = base + " WHERE IFNULL(schema_name, '') NOT LIKE 'x%' AND IFNULL(schema_name, '') NOT LIKE 'y' ORDER BY sum_timer_wait DESC LIMIT 100"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	// Invalid top
+	opts = map[string]string{
+		stmtdigest.OPT_TOP: "0",
+	}
+	if _, err = stmtdigest.DigestQuery(opts, digest.Help()); err == nil {
+		t.Error("no error for top=0, expected an error")
+	}
+}
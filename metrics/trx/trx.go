@@ -0,0 +1,238 @@
+// Copyright 2022 Block, Inc.
+
+package trx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "trx"
+
+	OPT_THRESHOLDS = "thresholds"
+
+	DEFAULT_THRESHOLDS = "1m,5m,15m"
+)
+
+const (
+	// As of MySQL 8.0, lock waits are in performance_schema.data_lock_waits
+	lockWaitQuery = "SELECT COUNT(DISTINCT t.trx_mysql_thread_id), IFNULL(MAX(TIMESTAMPDIFF(SECOND, t.trx_wait_started, NOW())), 0)" +
+		" FROM performance_schema.data_lock_waits w" +
+		" JOIN information_schema.innodb_trx t ON t.trx_id = w.requesting_engine_transaction_id"
+
+	// MySQL 5.7 and older
+	lockWaitQuery57 = "SELECT COUNT(DISTINCT t.trx_mysql_thread_id), IFNULL(MAX(TIMESTAMPDIFF(SECOND, t.trx_wait_started, NOW())), 0)" +
+		" FROM information_schema.innodb_lock_waits w" +
+		" JOIN information_schema.innodb_trx t ON t.trx_id = w.requesting_trx_id"
+)
+
+// Threshold is one transaction age from option thresholds.
+type Threshold struct {
+	Name    string // as configured, like "5m"
+	Seconds int
+}
+
+// Trx collects metrics for the trx domain. The sources are information_schema.innodb_trx
+// and performance_schema.data_lock_waits (information_schema.innodb_lock_waits
+// before MySQL 8.0).
+type Trx struct {
+	db *sql.DB
+	// --
+	trxQuery      map[string]string      // keyed on level
+	thresholds    map[string][]Threshold // keyed on level
+	lockWaitQuery string
+}
+
+var _ blip.Collector = &Trx{}
+
+func NewTrx(db *sql.DB) *Trx {
+	return &Trx{
+		db:            db,
+		trxQuery:      map[string]string{},
+		thresholds:    map[string][]Threshold{},
+		lockWaitQuery: lockWaitQuery,
+	}
+}
+
+func (c *Trx) Domain() string {
+	return DOMAIN
+}
+
+func (c *Trx) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Active InnoDB transactions and lock waits",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_THRESHOLDS: {
+				Name:    OPT_THRESHOLDS,
+				Desc:    "Comma-separated list of durations for metric older_than",
+				Default: DEFAULT_THRESHOLDS,
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "threshold", Value: "duration from option " + OPT_THRESHOLDS + " (only metric older_than)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "active",
+				Type: blip.GAUGE,
+				Desc: "Number of active transactions",
+			},
+			{
+				Name: "oldest_age",
+				Type: blip.GAUGE,
+				Desc: "Age of oldest active transaction (seconds)",
+			},
+			{
+				Name: "older_than",
+				Type: blip.GAUGE,
+				Desc: "Number of active transactions older than threshold",
+			},
+			{
+				Name: "blocked",
+				Type: blip.GAUGE,
+				Desc: "Number of sessions waiting for a lock",
+			},
+			{
+				Name: "lock_wait_max",
+				Type: blip.GAUGE,
+				Desc: "Longest current lock wait (seconds)",
+			},
+		},
+	}
+}
+
+// Prepare prepares queries for all levels in the plan that contain the trx domain.
+func (c *Trx) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	haveVersion := false
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		thresholds, err := ParseThresholds(blip.SetOrDefault(dom.Options[OPT_THRESHOLDS], DEFAULT_THRESHOLDS))
+		if err != nil {
+			return nil, err
+		}
+		c.thresholds[level.Name] = thresholds
+		c.trxQuery[level.Name] = TrxQuery(thresholds)
+
+		if haveVersion {
+			continue
+		}
+		major, _, _ := sqlutil.MySQLVersion(ctx, c.db)
+		if major == -1 {
+			blip.Debug("failed to get/parse MySQL version, ignoring")
+			continue
+		}
+		haveVersion = true
+		if major < 8 {
+			c.lockWaitQuery = lockWaitQuery57
+		}
+	}
+	return nil, nil
+}
+
+func (c *Trx) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.trxQuery[levelName]
+	if !ok {
+		return nil, nil // not collected in this level
+	}
+	thresholds := c.thresholds[levelName]
+
+	// Active transactions: count, oldest, then one count per threshold
+	vals := make([]float64, 2+len(thresholds))
+	dest := make([]interface{}, len(vals))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := c.db.QueryRowContext(ctx, q).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	metrics := []blip.MetricValue{
+		{
+			Name:  "active",
+			Type:  blip.GAUGE,
+			Value: vals[0],
+		},
+		{
+			Name:  "oldest_age",
+			Type:  blip.GAUGE,
+			Value: vals[1],
+		},
+	}
+	for i := range thresholds {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "older_than",
+			Type:  blip.GAUGE,
+			Value: vals[2+i],
+			Group: map[string]string{"threshold": thresholds[i].Name},
+		})
+	}
+
+	// Lock waits
+	var blocked, waitMax float64
+	if err := c.db.QueryRowContext(ctx, c.lockWaitQuery).Scan(&blocked, &waitMax); err != nil {
+		return nil, err
+	}
+	metrics = append(metrics,
+		blip.MetricValue{
+			Name:  "blocked",
+			Type:  blip.GAUGE,
+			Value: blocked,
+		},
+		blip.MetricValue{
+			Name:  "lock_wait_max",
+			Type:  blip.GAUGE,
+			Value: waitMax,
+		},
+	)
+
+	return metrics, nil
+}
+
+// ParseThresholds parses a comma-separated list of durations, like "1m,5m,15m".
+// Each duration must be at least one second.
+func ParseThresholds(s string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %s: %s", OPT_THRESHOLDS, v, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("invalid %s value: %s: must be at least 1s", OPT_THRESHOLDS, v)
+		}
+		thresholds = append(thresholds, Threshold{Name: v, Seconds: int(d.Seconds())})
+	}
+	return thresholds, nil
+}
+
+// TrxQuery returns the innodb_trx query that selects the number of active
+// transactions, age of the oldest transaction, and the number of transactions
+// older than each threshold, in that order.
+func TrxQuery(thresholds []Threshold) string {
+	cols := []string{
+		"COUNT(*)",
+		"IFNULL(MAX(TIMESTAMPDIFF(SECOND, trx_started, NOW())), 0)",
+	}
+	for _, t := range thresholds {
+		cols = append(cols, fmt.Sprintf("IFNULL(SUM(trx_started < NOW() - INTERVAL %d SECOND), 0)", t.Seconds))
+	}
+	return "SELECT " + strings.Join(cols, ", ") + " FROM information_schema.innodb_trx"
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Block, Inc.
+
+package trx_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip/metrics/trx"
+)
+
+func TestParseThresholds(t *testing.T) {
+	got, err := trx.ParseThresholds(trx.DEFAULT_THRESHOLDS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []trx.Threshold{
+		{Name: "1m", Seconds: 60},
+		{Name: "5m", Seconds: 300},
+		{Name: "15m", Seconds: 900},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := trx.ParseThresholds("1m,500ms"); err == nil {
+		t.Error("no error for threshold < 1s, expected an error")
+	}
+	if _, err := trx.ParseThresholds("5"); err == nil {
+		t.Error("no error for threshold without unit, expected an error")
+	}
+}
+
+func TestTrxQuery(t *testing.T) {
+	got := trx.TrxQuery([]trx.Threshold{{Name: "30s", Seconds: 30}})
+	expect := "SELECT COUNT(*), IFNULL(MAX(TIMESTAMPDIFF(SECOND, trx_started, NOW())), 0)," +
+		" IFNULL(SUM(trx_started < NOW() - INTERVAL 30 SECOND), 0)" +
+		" FROM information_schema.innodb_trx"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/metrics"
+	"github.com/cashapp/blip/metrics/blip"
 	"github.com/cashapp/blip/proto"
 	"github.com/cashapp/blip/status"
 )
@@ -33,6 +34,13 @@ type amc struct {
 	err     error
 }
 
+// domainStats are collector stats reported by the blip domain. Unlike amc,
+// they are kept when the plan changes because errors is a counter.
+type domainStats struct {
+	latency time.Duration // last collect
+	errors  uint64
+}
+
 // Engine does the real work: collect metrics.
 type Engine struct {
 	cfg       blip.ConfigMonitor
@@ -46,8 +54,9 @@ type Engine struct {
 	plan    blip.Plan
 	atLevel map[string][]blip.Collector // keyed on level
 
-	mcMux  *sync.Mutex
-	mcList map[string]*amc // keyed on domain
+	mcMux   *sync.Mutex
+	mcList  map[string]*amc         // keyed on domain
+	mcStats map[string]*domainStats // keyed on domain; kept across plans
 
 	statusMux *sync.Mutex
 	status    proto.MonitorEngineStatus
@@ -74,8 +83,9 @@ func NewEngine(cfg blip.ConfigMonitor, db *sql.DB) *Engine {
 		planMux: &sync.RWMutex{},
 		atLevel: map[string][]blip.Collector{},
 
-		mcMux:  &sync.Mutex{},
-		mcList: map[string]*amc{},
+		mcMux:   &sync.Mutex{},
+		mcList:  map[string]*amc{},
+		mcStats: map[string]*domainStats{},
 
 		statusMux: &sync.Mutex{},
 		status:    proto.MonitorEngineStatus{},
@@ -116,6 +126,28 @@ func (e *Engine) Status() proto.MonitorEngineStatus {
 	return cp
 }
 
+var _ blipmetrics.Source = &Engine{}
+
+// BlipMetrics returns collect counters and per-domain collect latency and errors
+// for the blip domain. It implements blipmetrics.Source.
+func (e *Engine) BlipMetrics() []blip.MetricValue {
+	metrics := []blip.MetricValue{
+		{Name: "collect_all", Type: blip.COUNTER, Value: float64(atomic.LoadUint64(&e.collectAll))},
+		{Name: "collect_some", Type: blip.COUNTER, Value: float64(atomic.LoadUint64(&e.collectSome))},
+		{Name: "collect_fail", Type: blip.COUNTER, Value: float64(atomic.LoadUint64(&e.collectFail))},
+	}
+	e.mcMux.Lock()
+	defer e.mcMux.Unlock()
+	for domain, s := range e.mcStats {
+		group := map[string]string{"domain": domain}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "domain_collect_latency", Type: blip.GAUGE, Value: float64(s.latency.Microseconds()) / 1000, Group: group},
+			blip.MetricValue{Name: "domain_collect_errors", Type: blip.COUNTER, Value: float64(s.errors), Group: group},
+		)
+	}
+	return metrics
+}
+
 // Prepare prepares the monitor to collect metrics for the plan. The monitor
 // must be successfully prepared for Collect() to work because Prepare()
 // initializes metrics collectors for every level of the plan. Prepare() can
@@ -278,6 +310,7 @@ func (e *Engine) Collect(ctx context.Context, levelName string) (*blip.Metrics,
 		Begin:     time.Now(),
 	}
 	errs := map[string]error{}
+	latency := map[string]time.Duration{}
 
 	// Collect metrics for each domain in parallel (limit: CollectParallel)
 	var wg sync.WaitGroup
@@ -307,10 +340,13 @@ func (e *Engine) Collect(ctx context.Context, levelName string) (*blip.Metrics,
 			// Collect metrics in this domain. This is where metrics collection
 			// happens: this domain-specific blip.Collector queries MySQL and
 			// returns blip.Metrics at this level.
+			t0 := time.Now()
 			vals, err := mc.Collect(ctx, levelName)
+			d := time.Since(t0)
 			// **************************************************************
 
 			mux.Lock()
+			latency[mc.Domain()] = d
 			errs[mc.Domain()] = err // clear or set error
 			if len(vals) > 0 {      // save metrics, if any
 				metrics.Values[mc.Domain()] = vals
@@ -327,6 +363,17 @@ func (e *Engine) Collect(ctx context.Context, levelName string) (*blip.Metrics,
 	errCount := 0
 	e.mcMux.Lock()
 	for domain, err := range errs {
+		// Update stats for blip domain
+		stats, ok := e.mcStats[domain]
+		if !ok {
+			stats = &domainStats{}
+			e.mcStats[domain] = stats
+		}
+		stats.latency = latency[domain] // zero if collector panicked
+		if err != nil {
+			stats.errors++
+		}
+
 		// Update MonitorEngineStatus: set new error or clear old error
 		if err == nil {
 			e.mcList[domain].err = nil
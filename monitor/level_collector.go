@@ -13,6 +13,7 @@ import (
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/event"
+	"github.com/cashapp/blip/metrics/blip"
 	"github.com/cashapp/blip/plan"
 	"github.com/cashapp/blip/proto"
 	"github.com/cashapp/blip/status"
@@ -38,9 +39,13 @@ type LevelCollector interface {
 
 	// Status returns detailed internal status.
 	Status() proto.MonitorCollectorStatus
+
+	// BlipMetrics returns metrics for the blip domain.
+	BlipMetrics() []blip.MetricValue
 }
 
 var _ LevelCollector = &lpc{}
+var _ blipmetrics.Source = &lpc{}
 
 // lpc is the implementation of LevelCollector.
 type lpc struct {
@@ -68,10 +73,18 @@ type lpc struct {
 	lastCollectError   error
 	lastCollectErrorTs time.Time
 	sinkErrors         map[string]error
+	levelBlocked       map[string]uint64     // keyed on level
+	sinkStats          map[string]*sinkStats // keyed on sink name
 
 	event event.MonitorReceiver
 }
 
+// sinkStats are sink stats reported by the blip domain.
+type sinkStats struct {
+	latency time.Duration // last send
+	errors  uint64
+}
+
 type LevelCollectorArgs struct {
 	Config           blip.ConfigMonitor
 	Engine           *Engine
@@ -95,8 +108,10 @@ func NewLevelCollector(args LevelCollectorArgs) *lpc {
 
 		changeMux: &sync.Mutex{},
 
-		statsMux:   &sync.Mutex{},
-		sinkErrors: map[string]error{},
+		statsMux:     &sync.Mutex{},
+		sinkErrors:   map[string]error{},
+		levelBlocked: map[string]uint64{},
+		sinkStats:    map[string]*sinkStats{},
 
 		event: event.MonitorReceiver{MonitorId: args.Config.MonitorId},
 	}
@@ -200,6 +215,9 @@ func (c *lpc) Run(stopChan, doneChan chan struct{}) error {
 			errMsg := fmt.Errorf("cannot callect %s/%s: %d of %d collectors still running",
 				c.plan.Name, c.levels[level].Name, maxCollectors, maxCollectors)
 			c.setErr(errMsg, event.LPC_BLOCKED)
+			c.statsMux.Lock()
+			c.levelBlocked[c.levels[level].Name]++
+			c.statsMux.Unlock()
 		}
 
 		c.stateMux.Unlock() // -- UNLOCK --
@@ -249,9 +267,24 @@ func (c *lpc) collect(levelName string) {
 	for i := range c.sinks {
 		sinkName := c.sinks[i].Name()
 		status.Monitor(c.monitorId, lpc, "%s/%s: sending to %s", c.plan.Name, levelName, sinkName)
+		t0 := time.Now()
 		err := c.sinks[i].Send(context.Background(), metrics)
+		d := time.Since(t0)
 		c.statsMux.Lock()
-		c.sinkErrors[sinkName] = fmt.Errorf("[%s] %s", time.Now(), err)
+		stats, ok := c.sinkStats[sinkName]
+		if !ok {
+			stats = &sinkStats{}
+			c.sinkStats[sinkName] = stats
+		}
+		stats.latency = d
+		if err != nil {
+			stats.errors++
+		}
+		if err != nil {
+			c.sinkErrors[sinkName] = fmt.Errorf("[%s] %s", time.Now(), err)
+		} else {
+			delete(c.sinkErrors, sinkName) // clear old error
+		}
 		c.statsMux.Unlock()
 	}
 }
@@ -480,8 +513,57 @@ func (c *lpc) Status() proto.MonitorCollectorStatus {
 		}
 		sinkErrors[sinkName] = err.Error()
 	}
+	// Errors that sinks don't return from Send, like Retry breaker state and
+	// dropped metrics
+	for i := range c.sinks {
+		r, ok := c.sinks[i].(blip.SinkErrorReporter)
+		if !ok {
+			continue
+		}
+		msg := r.SinkError()
+		if msg == "" {
+			continue
+		}
+		sinkName := c.sinks[i].Name()
+		if sinkErrors[sinkName] != "" {
+			msg = sinkErrors[sinkName] + "; " + msg
+		}
+		sinkErrors[sinkName] = msg
+	}
 	if len(sinkErrors) > 0 {
 		s.SinkErrors = sinkErrors
 	}
 	return s
 }
+
+// BlipMetrics returns blocked levels, per-sink send latency and errors, and
+// metrics from sinks that report their own, like Retry, for the blip domain.
+// It implements blipmetrics.Source. Blocked levels are the only skipped levels:
+// Run counts ticks, so if it falls behind, levels are delayed, not skipped.
+func (c *lpc) BlipMetrics() []blip.MetricValue {
+	metrics := []blip.MetricValue{}
+	c.statsMux.Lock()
+	for level, n := range c.levelBlocked {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "level_blocked",
+			Type:  blip.COUNTER,
+			Value: float64(n),
+			Group: map[string]string{"level": level},
+		})
+	}
+	for sinkName, s := range c.sinkStats {
+		group := map[string]string{"sink": sinkName}
+		metrics = append(metrics,
+			blip.MetricValue{Name: "sink_send_latency", Type: blip.GAUGE, Value: float64(s.latency.Microseconds()) / 1000, Group: group},
+			blip.MetricValue{Name: "sink_send_errors", Type: blip.COUNTER, Value: float64(s.errors), Group: group},
+		)
+	}
+	c.statsMux.Unlock()
+
+	for i := range c.sinks {
+		if src, ok := c.sinks[i].(blipmetrics.Source); ok {
+			metrics = append(metrics, src.BlipMetrics()...)
+		}
+	}
+	return metrics
+}
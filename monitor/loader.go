@@ -17,6 +17,7 @@ import (
 	"github.com/cashapp/blip/dbconn"
 	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/plan"
+	"github.com/cashapp/blip/prom"
 	"github.com/cashapp/blip/sink"
 	"github.com/cashapp/blip/status"
 )
@@ -559,6 +560,21 @@ func (ml *Loader) Monitors() []*Monitor {
 	return monitors
 }
 
+// Exporters returns the exporters of monitors running in shared exporter mode
+// (config exporter.shared), keyed on monitor ID. It's used by the API for the
+// shared exporter endpoint.
+func (ml *Loader) Exporters() map[string]prom.Exporter {
+	ml.Lock()
+	defer ml.Unlock()
+	exporters := map[string]prom.Exporter{}
+	for monitorId, loaded := range ml.dbmon {
+		if exp := loaded.monitor.Exporter(); exp != nil {
+			exporters[monitorId] = exp
+		}
+	}
+	return exporters
+}
+
 // Count returns the number of loaded monitors. It's used by the API for status.
 func (ml *Loader) Count() uint {
 	ml.Lock()
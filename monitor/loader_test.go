@@ -5,6 +5,7 @@ package monitor_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/cashapp/blip/dbconn"
 	"github.com/cashapp/blip/monitor"
 	"github.com/cashapp/blip/plan"
+	"github.com/cashapp/blip/prom"
 	"github.com/cashapp/blip/test/mock"
 )
 
@@ -64,3 +66,56 @@ func TestLoaderLoadOne(t *testing.T) {
 	expectIds := []string{moncfg.MonitorId}
 	assert.ElementsMatch(t, gotIds, expectIds)
 }
+
+func TestLoaderExportersShared(t *testing.T) {
+	// Monitor in shared exporter mode doesn't run a prom.API; instead, the
+	// Blip API gathers metrics through Loader.Exporters
+	shared := true
+	moncfg := blip.ConfigMonitor{
+		MonitorId: monitorId1,
+		Username:  "root",
+		Password:  "test",
+		Hostname:  "127.0.0.1:33560", // 5.6
+		Exporter: blip.ConfigExporter{
+			Mode:   blip.EXPORTER_MODE_LEGACY,
+			Shared: &shared,
+		},
+	}
+	cfg := blip.Config{
+		Monitors: []blip.ConfigMonitor{moncfg},
+	}
+
+	args := monitor.LoaderArgs{
+		Config: cfg,
+		Factories: blip.Factories{
+			DbConn: dbconn.NewConnFactory(nil, nil),
+		},
+		PlanLoader: plan.NewLoader(nil),
+		RDSLoader:  aws.RDSLoader{ClientFactory: mock.RDSClientFactory{}},
+	}
+	loader := monitor.NewLoader(args)
+	if err := loader.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	loader.StartMonitors()
+	defer loader.Unload(monitorId1)
+
+	var exp prom.Exporter
+	timeout := time.After(3 * time.Second)
+	for exp == nil {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for shared exporter")
+		case <-time.After(100 * time.Millisecond):
+		}
+		exp = loader.Exporters()[monitorId1]
+	}
+
+	mfs, err := exp.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) == 0 {
+		t.Error("no metrics gathered from shared exporter")
+	}
+}
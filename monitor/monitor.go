@@ -20,6 +20,7 @@ import (
 	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/ha"
 	"github.com/cashapp/blip/heartbeat"
+	"github.com/cashapp/blip/metrics/blip"
 	"github.com/cashapp/blip/plan"
 	"github.com/cashapp/blip/prom"
 	"github.com/cashapp/blip/proto"
@@ -48,14 +49,15 @@ type Monitor struct {
 	sinks      []blip.Sink
 
 	// Core components
-	runMux  *sync.RWMutex
-	db      *sql.DB
-	dsn     string
-	engine  *Engine
-	promAPI *prom.API
-	lpc     LevelCollector
-	lpa     LevelAdjuster
-	hbw     *heartbeat.Writer
+	runMux   *sync.RWMutex
+	db       *sql.DB
+	dsn      string
+	engine   *Engine
+	promAPI  *prom.API
+	exporter *Exporter // exporter.shared
+	lpc      LevelCollector
+	lpa      LevelAdjuster
+	hbw      *heartbeat.Writer
 
 	// Control chans and sync
 	stopMonitorChan chan struct{} // Stop(): stop the monitor
@@ -106,6 +108,17 @@ func (m *Monitor) MonitorId() string {
 	return m.monitorId
 }
 
+// Exporter returns the exporter if the monitor is running in shared exporter
+// mode (config exporter.shared), else nil.
+func (m *Monitor) Exporter() prom.Exporter {
+	m.runMux.RLock()
+	defer m.runMux.RUnlock()
+	if m.exporter == nil {
+		return nil
+	}
+	return m.exporter
+}
+
 // Config returns the monitor config.
 func (m *Monitor) Config() blip.ConfigMonitor {
 	return m.cfg
@@ -317,34 +330,38 @@ func (m *Monitor) start() error {
 		}
 		blip.Debug("%s: exporter plan: %s (%s)", m.monitorId, promPlan.Name, promPlan.Source)
 
-		// Run API to emulate an exporter, responding to GET /metrics
-		m.promAPI = prom.NewAPI(
-			m.cfg.Exporter,
-			m.monitorId,
-			NewExporter(m.cfg.Exporter, promPlan, NewEngine(m.cfg, m.db)),
-		)
-
-		m.wg.Add(1)
-		go func() {
-			defer status.RemoveComponent(m.monitorId, "exporter")
-			defer m.stop(true, "prom.API") // stop monitor goroutines
-			defer m.wg.Done()              // notify stop()
-			defer func() {                 // catch panic in exporter API
-				if r := recover(); r != nil {
-					b := make([]byte, 4096)
-					n := runtime.Stack(b, false)
-					errMsg := fmt.Errorf("PANIC: %s: %s\n%s", m.monitorId, r, string(b[0:n]))
-					m.setErr(errMsg, true)
+		exp := NewExporter(m.cfg.Exporter, promPlan, NewEngine(m.cfg, m.db))
+		if blip.True(m.cfg.Exporter.Shared) {
+			// Shared exporter endpoint on the Blip API (GET /metrics?target=monitorId)
+			// calls Exporter() instead of running a prom.API for this monitor
+			m.exporter = exp // runMux already locked
+			status.Monitor(m.monitorId, "exporter", "shared (GET /metrics?target=%s)", m.monitorId)
+		} else {
+			// Run API to emulate an exporter, responding to GET /metrics
+			m.promAPI = prom.NewAPI(m.cfg.Exporter, m.monitorId, exp)
+
+			m.wg.Add(1)
+			go func() {
+				defer status.RemoveComponent(m.monitorId, "exporter")
+				defer m.stop(true, "prom.API") // stop monitor goroutines
+				defer m.wg.Done()              // notify stop()
+				defer func() {                 // catch panic in exporter API
+					if r := recover(); r != nil {
+						b := make([]byte, 4096)
+						n := runtime.Stack(b, false)
+						errMsg := fmt.Errorf("PANIC: %s: %s\n%s", m.monitorId, r, string(b[0:n]))
+						m.setErr(errMsg, true)
+					}
+				}()
+				err := m.promAPI.Run()
+				if err == nil { // shutdown
+					blip.Debug("%s: prom api stopped", m.monitorId)
+					return
 				}
+				blip.Debug("%s: prom api error: %s", m.monitorId, err.Error())
+				status.Monitor(m.monitorId, "exporter", "API error (restart in 1s): %s", err)
 			}()
-			err := m.promAPI.Run()
-			if err == nil { // shutdown
-				blip.Debug("%s: prom api stopped", m.monitorId)
-				return
-			}
-			blip.Debug("%s: prom api error: %s", m.monitorId, err.Error())
-			status.Monitor(m.monitorId, "exporter", "API error (restart in 1s): %s", err)
-		}()
+		}
 
 		if m.cfg.Exporter.Mode == blip.EXPORTER_MODE_LEGACY {
 			blip.Debug("%s: legacy mode", m.monitorId)
@@ -367,6 +384,7 @@ func (m *Monitor) start() error {
 		PlanLoader: m.planLoader,
 		Sinks:      m.sinks,
 	})
+	blipmetrics.SetSources(m.monitorId, m.engine, m.lpc) // blip domain
 
 	m.wg.Add(1)
 	go func() {
@@ -457,6 +475,7 @@ func (m *Monitor) stop(lock bool, caller string) {
 	if m.promAPI != nil {
 		m.promAPI.Stop()
 	}
+	m.exporter = nil
 
 	// Wait for monitor gourtines to return
 	status.Monitor(m.monitorId, "monitor", "stopping goroutines")
@@ -471,6 +490,13 @@ func (m *Monitor) Restart() error {
 	// Stop and wait for monitor goroutines
 	m.stop(false, "Stop")
 
+	// Stop sinks that buffer metrics, like Retry with a spool
+	for _, sink := range m.sinks {
+		if s, ok := sink.(blip.SinkStopper); ok {
+			s.Stop()
+		}
+	}
+
 	// Everything should be stopped now, so close db connection
 	if m.db != nil {
 		m.db.Close()
@@ -501,11 +527,20 @@ func (m *Monitor) Stop() error {
 	// Stop and wait for monitor goroutines
 	m.stop(false, "Stop")
 
+	// Stop sinks that buffer metrics, like Retry with a spool
+	for _, sink := range m.sinks {
+		if s, ok := sink.(blip.SinkStopper); ok {
+			s.Stop()
+		}
+	}
+
 	// Everything should be stopped now, so close db connection
 	if m.db != nil {
 		m.db.Close()
 	}
 
+	blipmetrics.RemoveSources(m.monitorId)
+
 	event.Sendf(event.MONITOR_STOPPED, m.monitorId)
 	status.Monitor(m.monitorId, "monitor", "stopped at %s", time.Now())
 	return nil
@@ -6,10 +6,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 
 	"github.com/cashapp/blip"
@@ -42,10 +46,176 @@ func ExporterPlan(cfg blip.ConfigExporter, plan blip.Plan) (blip.Plan, error) {
 			return blip.Plan{}, fmt.Errorf("invalid plan level name: %s: must be 'prom'", levelName)
 		}
 	}
-	// @todo modify plan based on ConfigExporter.Flags
+	return exporterFlags(cfg.Flags, plan)
+}
+
+// mysqld_exporter collect.* flag (without prefix) => Blip domain. Other collect.*
+// flags are not supported and ignored (or an error if strict).
+var exporterCollectors = map[string]string{
+	"global_status":                   "status.global",
+	"global_variables":                "var.global",
+	"info_schema.innodb_metrics":      "innodb",
+	"slave_status":                    "repl",
+	"binlog_size":                     "size.binlog",
+	"info_schema.tables":              "size.table",
+	"info_schema.query_response_time": "percona.response-time",
+	"info_schema.processlist":         "processlist",
+}
+
+// mysqld_exporter flags that configure a collector, handled after collectors
+// are enabled or disabled.
+const (
+	flagTablesDatabases     = "collect.info_schema.tables.databases"
+	flagProcesslistMinTime  = "collect.info_schema.processlist.min_time"
+	flagProcessesByUser     = "collect.info_schema.processlist.processes_by_user"
+	flagProcessesByHost     = "collect.info_schema.processlist.processes_by_host"
+	exporterCollectorPrefix = "collect."
+)
+
+// exporterTablesTop is size.table option top for collect.info_schema.tables.
+// mysqld_exporter reports every table, but size.table reports only the top 100
+// largest tables by default.
+const exporterTablesTop = "1000000"
+
+// exporterDomain returns the Blip domain configured like the mysqld_exporter
+// collector. Default collectors are configured in blip.PromPlan.
+func exporterDomain(domain string) blip.Domain {
+	if dom, ok := blip.PromPlan().Levels["prom"].Collect[domain]; ok {
+		return dom
+	}
+	switch domain {
+	case "size.binlog":
+		return blip.Domain{
+			Name:    domain,
+			Metrics: []string{"bytes", "files", "file_number"},
+		}
+	case "size.table":
+		return blip.Domain{
+			Name:    domain,
+			Options: map[string]string{"top": exporterTablesTop},
+		}
+	case "processlist":
+		return blip.Domain{
+			Name:    domain,
+			Options: map[string]string{},
+			Metrics: []string{"threads", "seconds", "processes_by_user", "processes_by_host"},
+		}
+	}
+	return blip.Domain{Name: domain}
+}
+
+// exporterFlags returns a copy of the plan with domains added or removed by
+// mysqld_exporter collect.* flags, like --collect.binlog_size. Like the real
+// exporter, a flag without a value is true, and prefix "no-" negates the flag:
+// no-collect.slave_status is the same as collect.slave_status=false.
+func exporterFlags(flags map[string]string, plan blip.Plan) (blip.Plan, error) {
+	level := plan.Levels["prom"]
+	collect := make(map[string]blip.Domain, len(level.Collect))
+	for domain, dom := range level.Collect {
+		collect[domain] = dom
+	}
+
+	// Sort flags so the plan is deterministic
+	names := make([]string, 0, len(flags))
+	for k := range flags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	// Enable or disable collectors (domains)
+	opts := map[string]string{}
+	for _, k := range names {
+		name := strings.TrimPrefix(k, "no-")
+		if !strings.HasPrefix(name, exporterCollectorPrefix) {
+			continue // not a collector flag, like web.listen-address
+		}
+		if name == flagTablesDatabases || name == flagProcesslistMinTime {
+			opts[name] = flags[k] // not a boolean flag
+			continue
+		}
+		on, err := exporterBool(k, flags[k])
+		if err != nil {
+			return blip.Plan{}, err
+		}
+		if name != k {
+			on = !on // no-collect.*
+		}
+		if name == flagProcessesByUser || name == flagProcessesByHost {
+			opts[name] = strconv.FormatBool(on)
+			continue
+		}
+		domain, ok := exporterCollectors[strings.TrimPrefix(name, exporterCollectorPrefix)]
+		if !ok {
+			if blip.Strict {
+				return blip.Plan{}, fmt.Errorf("unsupported exporter flag: %s", k)
+			}
+			blip.Debug("unsupported exporter flag: %s, ignoring", k)
+			continue
+		}
+		if !on {
+			delete(collect, domain)
+			continue
+		}
+		if _, ok := collect[domain]; !ok {
+			collect[domain] = exporterDomain(domain)
+		}
+	}
+
+	// Configure collectors
+	if dom, ok := collect["size.table"]; ok {
+		if v := opts[flagTablesDatabases]; v != "" && v != "*" {
+			dom.Options = copyOptions(dom.Options)
+			dom.Options["include"] = v
+			collect["size.table"] = dom
+		}
+	}
+	if dom, ok := collect["processlist"]; ok {
+		if v := opts[flagProcesslistMinTime]; v != "" {
+			dom.Options = copyOptions(dom.Options)
+			dom.Options["min-time"] = v
+		}
+		if len(dom.Metrics) == 0 {
+			dom.Metrics = exporterDomain("processlist").Metrics // all
+		}
+		metrics := []string{}
+		for _, m := range dom.Metrics {
+			if (m == "processes_by_user" && opts[flagProcessesByUser] == "false") ||
+				(m == "processes_by_host" && opts[flagProcessesByHost] == "false") {
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+		dom.Metrics = metrics
+		collect["processlist"] = dom
+	}
+
+	level.Name = "prom" // collectors are prepared by level.Name but collected by key
+	level.Collect = collect
+	plan.Levels = map[string]blip.Level{"prom": level}
 	return plan, nil
 }
 
+// exporterBool returns the boolean value of a flag. Like the real exporter, a
+// flag without a value is true.
+func exporterBool(flag, v string) (bool, error) {
+	if v == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid exporter flag value: %s=%s: must be true or false", flag, v)
+	}
+	return b, nil
+}
+
+func copyOptions(opts map[string]string) map[string]string {
+	c := make(map[string]string, len(opts)+1)
+	for k, v := range opts {
+		c[k] = v
+	}
+	return c
+}
+
 func NewExporter(cfg blip.ConfigExporter, plan blip.Plan, engine *Engine) *Exporter {
 	e := &Exporter{
 		cfg:          cfg,
@@ -66,11 +236,11 @@ func (e Exporter) Plan() blip.Plan {
 // --------------------------------------------------------------------------
 // Implement Prometheus collector
 
-// Scrape collects and returns metrics in Prometheus exposition format.
-// This function is called in response to GET /metrics.
+// Scrape collects and returns metrics in Prometheus text exposition format.
+// GET /metrics does not call this function; it calls Gather by way of prom.Handler,
+// which negotiates the format and streams the response.
 func (e Exporter) Scrape() (string, error) {
-	// Gather calls the Collect method of the exporter
-	mfs, err := e.promRegistry.Gather()
+	mfs, err := e.Gather()
 	if err != nil {
 		return "", fmt.Errorf("Unable to convert blip metrics to Prom metrics. Error: %s", err)
 	}
@@ -84,21 +254,27 @@ func (e Exporter) Scrape() (string, error) {
 	return buf.String(), nil
 }
 
+// Gather collects and returns metrics as Prometheus MetricFamily protobufs.
+// It implements prometheus.Gatherer, and it is called in response to GET /metrics.
+func (e Exporter) Gather() ([]*dto.MetricFamily, error) {
+	// Gather calls the Collect method of the exporter
+	return e.promRegistry.Gather()
+}
+
 func (e Exporter) Describe(descs chan<- *prometheus.Desc) {
 	// Left empty intentionally to make the collector unchecked.
 }
 
 var noop = func() {}
 
-// Collect collects metrics. It is called indirectly via Scrpe.
+// Collect collects metrics. It is called indirectly via Gather.
 func (e Exporter) Collect(ch chan<- prometheus.Metric) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	e.Lock()
 	if !e.prepared {
-		plan := blip.PromPlan()
-		if err := e.engine.Prepare(ctx, plan, noop, noop); err != nil {
+		if err := e.engine.Prepare(ctx, e.plan, noop, noop); err != nil {
 			blip.Debug(err.Error())
 			e.Unlock()
 			return
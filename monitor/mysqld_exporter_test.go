@@ -3,9 +3,12 @@
 package monitor_test
 
 import (
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/go-test/deep"
+
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/monitor"
 )
@@ -35,3 +38,62 @@ mysql_global_variables_port 3306`
 		t.Errorf("output does not contain:\n%s\n\n%s\n", expect, got)
 	}
 }
+
+func TestExporterPlan(t *testing.T) {
+	cfg := blip.ConfigExporter{
+		Flags: map[string]string{
+			"web.listen-address":                                "127.0.0.1:9104",
+			"collect.binlog_size":                               "",
+			"collect.info_schema.innodb_metrics":                "false",
+			"no-collect.slave_status":                           "",
+			"collect.info_schema.processlist":                   "true",
+			"collect.info_schema.processlist.min_time":          "5",
+			"collect.info_schema.processlist.processes_by_host": "false",
+			"collect.perf_schema.eventsstatements":              "true", // not supported
+		},
+	}
+	plan, err := monitor.ExporterPlan(cfg, blip.InternalLevelPlan())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := plan.Levels["prom"].Collect
+	got := []string{}
+	for domain := range collect {
+		got = append(got, domain)
+	}
+	sort.Strings(got)
+	expect := []string{"percona.response-time", "processlist", "size.binlog", "status.global", "var.global"}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	pl := collect["processlist"]
+	if pl.Options["min-time"] != "5" {
+		t.Errorf("processlist min-time = %s, expected 5", pl.Options["min-time"])
+	}
+	if diff := deep.Equal(pl.Metrics, []string{"threads", "seconds", "processes_by_user"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Default plan not modified
+	if _, ok := blip.PromPlan().Levels["prom"].Collect["innodb"]; !ok {
+		t.Error("innodb removed from blip.PromPlan")
+	}
+
+	// Like mysqld_exporter, report all tables, not only size.table default top 100
+	cfg.Flags = map[string]string{"collect.info_schema.tables": "", "collect.info_schema.tables.databases": "app"}
+	plan, err = monitor.ExporterPlan(cfg, blip.InternalLevelPlan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := plan.Levels["prom"].Collect["size.table"]
+	if diff := deep.Equal(st.Options, map[string]string{"top": "1000000", "include": "app"}); diff != nil {
+		t.Error(diff)
+	}
+
+	cfg.Flags = map[string]string{"collect.binlog_size": "maybe"}
+	if _, err := monitor.ExporterPlan(cfg, blip.InternalLevelPlan()); err == nil {
+		t.Error("no error for invalid flag value, expected an error")
+	}
+}
@@ -309,7 +309,7 @@ func PromPlan() Plan {
 		Source: "blip",
 		Levels: map[string]Level{
 			"prom": Level{
-				Name: "all",
+				Name: "prom",
 				Freq: "0", // none, pulled/scaped on demand
 				Collect: map[string]Domain{
 					"status.global": {
@@ -330,6 +330,29 @@ func PromPlan() Plan {
 							"all": "enabled",
 						},
 					},
+					"repl": {
+						Name: "repl",
+						Metrics: []string{
+							"running",
+							"io_running",
+							"sql_running",
+							"seconds_behind",
+							"relay_log_space",
+							"read_source_log_pos",
+							"exec_source_log_pos",
+							"relay_log_pos",
+							"sql_delay",
+							"last_errno",
+							"last_io_errno",
+						},
+					},
+					"percona.response-time": {
+						Name: "percona.response-time",
+						Options: map[string]string{
+							"histogram": "yes",
+							"flush":     "no",
+						},
+					},
 				},
 			},
 		},
@@ -538,6 +538,16 @@ func ValidatePlans(plans []blip.Plan) error {
 				if err != nil {
 					errMsgs = append(errMsgs, fmt.Sprintf("invalid plan: %s: at %s/%s: %s",
 						plans[i].Name, levelName, domainName, err))
+					continue DOMAINS
+				}
+
+				// Some collectors, like sql.custom, also validate option values
+				if v, ok := mc.(blip.OptionValidator); ok {
+					err := v.ValidateOptions(plans[i].Levels[levelName].Collect[domainName].Options)
+					if err != nil {
+						errMsgs = append(errMsgs, fmt.Sprintf("invalid plan: %s: at %s/%s: %s",
+							plans[i].Name, levelName, domainName, err))
+					}
 				}
 			}
 		}
@@ -65,3 +65,36 @@ func TestLoadOneFile(t *testing.T) {
 	}
 	assert.Equal(t, gotPlans, expectPlans)
 }
+
+func TestValidatePlansOptionValidator(t *testing.T) {
+	// sql.custom implements blip.OptionValidator, so ValidatePlans should
+	// catch an invalid query even though the option name is valid
+	plans := []blip.Plan{
+		{
+			Name: "custom",
+			Levels: map[string]blip.Level{
+				"kpi": {
+					Name: "kpi",
+					Freq: "5s",
+					Collect: map[string]blip.Domain{
+						"sql.custom": {
+							Name: "sql.custom",
+							Options: map[string]string{
+								"query":   "SELECT COUNT(*) AS n FROM app.jobs",
+								"metrics": "n:gauge",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := plan.ValidatePlans(plans); err != nil {
+		t.Errorf("got error for valid plan: %s", err)
+	}
+
+	plans[0].Levels["kpi"].Collect["sql.custom"].Options["query"] = "DELETE FROM app.jobs"
+	if err := plan.ValidatePlans(plans); err == nil {
+		t.Error("no error for DELETE query, expected an error")
+	}
+}
@@ -5,19 +5,24 @@ package prom
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/status"
 )
 
-// Exporter emulates a Prometheus mysqld_exporter.
+// Exporter emulates a Prometheus mysqld_exporter. It is a prometheus.Gatherer.
 type Exporter interface {
-	Scrape() (string, error)
+	Gather() ([]*dto.MetricFamily, error)
 }
 
-// API emulates a Prometheus exporter API. It uses an Exporter to scape metrics
+// API emulates a Prometheus exporter API. It uses an Exporter to gather metrics
 // when GET /metrics is called.
 type API struct {
 	cfg       blip.ConfigExporter
@@ -47,7 +52,7 @@ func (api *API) Run() error {
 
 	path := blip.SetOrDefault(api.cfg.Flags["web.telemetry-path"], blip.DEFAULT_EXPORTER_PATH)
 	mux := http.NewServeMux()
-	mux.HandleFunc(path, api.metricsHandler)
+	mux.Handle(path, Handler(api.monitorId, api.exp))
 	api.srv.Handler = mux
 
 	err := api.srv.ListenAndServe() // blocks
@@ -61,12 +66,23 @@ func (api *API) Stop() {
 	api.srv.Shutdown(context.Background())
 }
 
-func (api *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
-	expo, err := api.exp.Scrape()
-	if err != nil {
-		blip.Debug(err.Error())
-	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, expo)
+// Handler returns the handler that serves metrics gathered from g. Like the
+// real exporter, the exposition format is negotiated by the Accept header (Prometheus
+// text, OpenMetrics text, or protobuf), the response is gzip compressed if the
+// Accept-Encoding header allows, and metric families are encoded and written to
+// the response one by one. Errors are logged with blip.Debug and prefixed with id.
+// Metrics gathered without error are still served.
+func Handler(id string, g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		ErrorLog:          debugLog(id),
+		ErrorHandling:     promhttp.ContinueOnError,
+		EnableOpenMetrics: true,
+	})
+}
+
+// debugLog is a promhttp.Logger that logs with blip.Debug.
+type debugLog string
+
+func (id debugLog) Println(v ...interface{}) {
+	blip.Debug("%s: %s", string(id), strings.TrimSpace(fmt.Sprintln(v...)))
 }
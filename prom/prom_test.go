@@ -10,16 +10,11 @@ import (
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/prom"
-	"github.com/cashapp/blip/test/mock"
 )
 
 func TestAPI(t *testing.T) {
-	expect := "fake output"
-	exp := mock.Exporter{
-		ScrapeFunc: func() (string, error) {
-			return expect, nil
-		},
-	}
+	expect := "# HELP mysql_up up\n# TYPE mysql_up gauge\nmysql_up 1\n"
+	exp := testExporter(1)
 
 	addr := "127.0.0.1:9991"
 
@@ -0,0 +1,140 @@
+// Copyright 2022 Block, Inc.
+
+package prom
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/cashapp/blip"
+)
+
+// MONITOR_ID_LABEL is the label added to every metric by the shared exporter
+// endpoint when it merges metrics from all monitors.
+const MONITOR_ID_LABEL = "monitor_id"
+
+// Targets returns the exporters of monitors in shared exporter mode (config
+// exporter.shared), keyed on monitor ID.
+type Targets func() map[string]Exporter
+
+// SharedHandler returns the handler for the shared exporter endpoint on the Blip
+// API. Like the Prometheus multi-target exporter pattern, GET /metrics?target=<monitorId>
+// returns metrics for the monitor. If merge is true, GET /metrics (without
+// target) returns metrics for all monitors with label monitor_id; else, target
+// is required.
+func SharedHandler(targets Targets, merge bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		exporters := targets()
+
+		if target == "" {
+			if !merge {
+				http.Error(w, "missing URL query: ?target=monitorId", http.StatusBadRequest)
+				return
+			}
+			merged := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				return Merge(exporters), nil
+			})
+			Handler("merged", merged).ServeHTTP(w, r)
+			return
+		}
+
+		exp, ok := exporters[target]
+		if !ok {
+			errMsg := html.EscapeString(fmt.Sprintf("target %s not found (monitor not loaded or not in shared exporter mode)", target))
+			http.Error(w, errMsg, http.StatusNotFound)
+			return
+		}
+		Handler(target, exp).ServeHTTP(w, r)
+	}
+}
+
+// MERGE_WORKERS is the maximum number of exporters that Merge gathers from
+// concurrently.
+const MERGE_WORKERS = 16
+
+// Merge gathers metrics from all exporters and returns them as one list of metric
+// families sorted by name. Every metric has label monitor_id. Metrics from an
+// exporter that returns an error are not included, and a metric family with the
+// same name but a different type than the first one is not included.
+//
+// Exporters are gathered concurrently, up to MERGE_WORKERS at a time, but the
+// slowest exporters still determine the response time, so merging is meant for
+// small fleets. For many monitors, scrape each target separately.
+func Merge(exporters map[string]Exporter) []*dto.MetricFamily {
+	ids := make([]string, 0, len(exporters))
+	for monitorId := range exporters {
+		ids = append(ids, monitorId)
+	}
+	sort.Strings(ids)
+
+	// Gather concurrently, saving results by index to merge in monitor ID order
+	gathered := make([][]*dto.MetricFamily, len(ids))
+	idChan := make(chan int, len(ids))
+	for i := range ids {
+		idChan <- i
+	}
+	close(idChan)
+	workers := MERGE_WORKERS
+	if len(ids) < workers {
+		workers = len(ids)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range idChan {
+				mfs, err := exporters[ids[i]].Gather()
+				if err != nil {
+					blip.Debug("%s: %s", ids[i], err)
+					continue
+				}
+				gathered[i] = mfs
+			}
+		}()
+	}
+	wg.Wait()
+
+	merged := map[string]*dto.MetricFamily{}
+	for i := range ids {
+		monitorId := ids[i]
+		mfs := gathered[i]
+		labelName := MONITOR_ID_LABEL
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				m.Label = append(m.Label, &dto.LabelPair{Name: &labelName, Value: &monitorId})
+				sort.Slice(m.Label, func(i, j int) bool {
+					return m.Label[i].GetName() < m.Label[j].GetName()
+				})
+			}
+			first, ok := merged[mf.GetName()]
+			if !ok {
+				merged[mf.GetName()] = mf
+				continue
+			}
+			if first.GetType() != mf.GetType() {
+				blip.Debug("%s: %s type %s, expected %s, ignoring", monitorId, mf.GetName(), mf.GetType(), first.GetType())
+				continue
+			}
+			first.Metric = append(first.Metric, mf.Metric...)
+		}
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mfs := make([]*dto.MetricFamily, len(names))
+	for i, name := range names {
+		mfs[i] = merged[name]
+	}
+	return mfs
+}
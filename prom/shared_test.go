@@ -0,0 +1,142 @@
+// Copyright 2022 Block, Inc.
+
+package prom_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/cashapp/blip/prom"
+	"github.com/cashapp/blip/test/mock"
+)
+
+func testExporter(value float64) mock.Exporter {
+	return mock.Exporter{
+		GatherFunc: func() ([]*dto.MetricFamily, error) {
+			reg := prometheus.NewRegistry()
+			g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "mysql_up", Help: "up"})
+			g.Set(value)
+			reg.MustRegister(g)
+			return reg.Gather()
+		},
+	}
+}
+
+func TestSharedHandler(t *testing.T) {
+	exporters := map[string]prom.Exporter{
+		"db1": testExporter(1),
+		"db2": testExporter(0),
+	}
+	targets := func() map[string]prom.Exporter { return exporters }
+
+	tests := []struct {
+		url    string
+		merge  bool
+		status int
+		body   string
+	}{
+		{"/metrics?target=db1", false, http.StatusOK, "# HELP mysql_up up\n# TYPE mysql_up gauge\nmysql_up 1\n"},
+		{"/metrics?target=db2", true, http.StatusOK, "# HELP mysql_up up\n# TYPE mysql_up gauge\nmysql_up 0\n"},
+		{"/metrics?target=db3", false, http.StatusNotFound, ""},
+		{"/metrics", false, http.StatusBadRequest, ""},
+		{"/metrics", true, http.StatusOK, "# HELP mysql_up up\n# TYPE mysql_up gauge\n" +
+			"mysql_up{monitor_id=\"db1\"} 1\nmysql_up{monitor_id=\"db2\"} 0\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		prom.SharedHandler(targets, test.merge)(w, httptest.NewRequest("GET", test.url, nil))
+		if w.Code != test.status {
+			t.Errorf("%s (merge=%t): got HTTP status %d, expected %d", test.url, test.merge, w.Code, test.status)
+			continue
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s (merge=%t): got '%s', expected '%s'", test.url, test.merge, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestHandlerNegotiation(t *testing.T) {
+	h := prom.Handler("db1", testExporter(1))
+
+	// OpenMetrics
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("got Content-Type %s, expected application/openmetrics-text", ct)
+	}
+	if body := w.Body.String(); !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("OpenMetrics body does not end with # EOF: %s", body)
+	}
+
+	// Protobuf
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/vnd.google.protobuf") {
+		t.Errorf("got Content-Type %s, expected application/vnd.google.protobuf", ct)
+	}
+	mf := &dto.MetricFamily{}
+	if err := expfmt.NewDecoder(w.Body, expfmt.FmtProtoDelim).Decode(mf); err != nil {
+		t.Fatal(err)
+	}
+	if mf.GetName() != "mysql_up" || mf.Metric[0].GetGauge().GetValue() != 1 {
+		t.Errorf("got %s, expected mysql_up 1", mf.String())
+	}
+
+	// Gzip (default text format)
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("got Content-Encoding %s, expected gzip", ce)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gz)
+	expect := "# HELP mysql_up up\n# TYPE mysql_up gauge\nmysql_up 1\n"
+	if string(body) != expect {
+		t.Errorf("got '%s', expected '%s'", body, expect)
+	}
+}
+
+func TestMergeConcurrent(t *testing.T) {
+	exporters := map[string]prom.Exporter{}
+	for _, id := range []string{"db1", "db2", "db3", "db4"} {
+		exp := testExporter(1)
+		gather := exp.GatherFunc
+		exp.GatherFunc = func() ([]*dto.MetricFamily, error) {
+			time.Sleep(200 * time.Millisecond) // slow collect
+			return gather()
+		}
+		exporters[id] = exp
+	}
+
+	t0 := time.Now()
+	mfs := prom.Merge(exporters)
+	if d := time.Since(t0); d >= 800*time.Millisecond {
+		t.Errorf("Merge took %s, expected < 800ms (exporters gathered concurrently)", d)
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != 4 {
+		t.Fatalf("got %v, expected 1 metric family with 4 metrics", mfs)
+	}
+	for i, id := range []string{"db1", "db2", "db3", "db4"} {
+		if got := mfs[0].Metric[i].Label[0].GetValue(); got != id {
+			t.Errorf("metric %d monitor_id = %s, expected %s", i, got, id)
+		}
+	}
+}
@@ -36,4 +36,13 @@ var trRepo = map[string]DomainTranslator{
 	"status.global": tr.StatusGlobal{Domain: "global_status", ShortDomain: "status"},
 	"var.global":    tr.Generic{Domain: "global_variables", ShortDomain: "var"},
 	"innodb":        tr.InnoDBMetrics{Domain: "info_schema_innodb", ShortDomain: "innodb"},
+	"blip":          tr.Blip{Domain: "blip", ShortDomain: "blip"},
+
+	// mysqld_exporter metric names and labels
+	"repl":                  tr.Repl{Domain: "slave_status", ShortDomain: "repl"},
+	"size.binlog":           tr.Binlog{Domain: "binlog", ShortDomain: "binlog"},
+	"size.database":         tr.DatabaseSize{Domain: "info_schema", ShortDomain: "database"},
+	"size.table":            tr.TableSize{Domain: "info_schema", ShortDomain: "table"},
+	"percona.response-time": tr.QRT{Domain: "info_schema", ShortDomain: "qrt"},
+	"processlist":           tr.Processlist{Domain: "info_schema", ShortDomain: "processlist"},
 }
@@ -0,0 +1,55 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// Binlog translates the size.binlog domain to mysqld_exporter binlog_size metrics.
+type Binlog struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr Binlog) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// Copied from /percona/mysqld_exporter/collector/binlog.go
+var (
+	binlogSizeDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "binlog", "size_bytes"),
+		"Combined size of all registered binlog files.",
+		[]string{}, nil,
+	)
+	binlogFilesDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "binlog", "files"),
+		"Number of registered binlog files.",
+		[]string{}, nil,
+	)
+	binlogFileNumberDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "binlog", "file_number"),
+		"The last binlog file number.",
+		[]string{}, nil,
+	)
+)
+
+func (tr Binlog) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	for i := range values {
+		var desc *prom.Desc
+		switch values[i].Name {
+		case "bytes":
+			desc = binlogSizeDesc
+		case "files":
+			desc = binlogFilesDesc
+		case "file_number":
+			desc = binlogFileNumberDesc
+		default:
+			continue
+		}
+		ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, values[i].Value)
+	}
+}
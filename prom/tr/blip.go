@@ -0,0 +1,58 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// Blip translates the blip domain (self-telemetry). Unlike Generic, it makes
+// the domain, level, and sink groups labels, else per-domain, per-level, and
+// per-sink values would be duplicate metrics.
+type Blip struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr Blip) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// blipLabels are the blip domain group keys, in label order.
+var blipLabels = []string{"domain", "level", "sink"}
+
+func (tr Blip) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	for i := range values {
+		var promType prom.ValueType
+		var help string
+		switch values[i].Type {
+		case blip.COUNTER:
+			promType = prom.CounterValue
+			help = "Blip counter metric."
+		case blip.GAUGE:
+			promType = prom.GaugeValue
+			help = "Blip gauge metric."
+		}
+
+		var labels, labelValues []string
+		for _, k := range blipLabels {
+			if v, ok := values[i].Group[k]; ok {
+				labels = append(labels, k)
+				labelValues = append(labelValues, v)
+			}
+		}
+
+		ch <- prom.MustNewConstMetric(
+			prom.NewDesc(
+				prom.BuildFQName(GENERIC_PREFIX, tr.Domain, validPrometheusName(values[i].Name)),
+				help,
+				labels, nil,
+			),
+			promType,
+			values[i].Value,
+			labelValues...,
+		)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// Processlist translates the processlist domain to mysqld_exporter
+// info_schema.processlist metrics.
+type Processlist struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr Processlist) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// Copied from /percona/mysqld_exporter/collector/info_schema_processlist.go
+var (
+	processlistCountDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "processlist_threads"),
+		"The number of threads split by current state.",
+		[]string{"command", "state"}, nil,
+	)
+	processlistTimeDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "processlist_seconds"),
+		"The number of seconds threads have used split by current state.",
+		[]string{"command", "state"}, nil,
+	)
+	processesByUserDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "processlist_processes_by_user"),
+		"The number of processes by user.",
+		[]string{"mysql_user"}, nil,
+	)
+	processesByHostDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "processlist_processes_by_host"),
+		"The number of processes by host.",
+		[]string{"client_host"}, nil,
+	)
+)
+
+// Copied from /percona/mysqld_exporter/collector/info_schema_processlist.go
+var stateReplacer = strings.NewReplacer(
+	";", "",
+	",", "",
+	":", "",
+	".", "",
+	"(", "",
+	")", "",
+	" ", "_",
+	"-", "_",
+)
+
+func sanitizeState(state string) string {
+	if state == "" {
+		state = "unknown"
+	}
+	return stateReplacer.Replace(strings.ToLower(state))
+}
+
+func (tr Processlist) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	// Sanitizing can make different Blip groups the same labels, like "Sleep"
+	// and "sleep", so values are summed by labels to avoid duplicate metrics
+	type key struct {
+		desc   *prom.Desc
+		labels [2]string
+	}
+	sum := map[key]float64{}
+	order := []key{}
+	for i := range values {
+		var k key
+		switch values[i].Name {
+		case "threads":
+			k = key{processlistCountDesc, [2]string{sanitizeState(values[i].Group["command"]), sanitizeState(values[i].Group["state"])}}
+		case "seconds":
+			k = key{processlistTimeDesc, [2]string{sanitizeState(values[i].Group["command"]), sanitizeState(values[i].Group["state"])}}
+		case "processes_by_user":
+			k = key{processesByUserDesc, [2]string{values[i].Group["user"]}}
+		case "processes_by_host":
+			k = key{processesByHostDesc, [2]string{values[i].Group["host"]}}
+		default:
+			continue
+		}
+		if _, ok := sum[k]; !ok {
+			order = append(order, k)
+		}
+		sum[k] += values[i].Value
+	}
+	for _, k := range order {
+		labels := k.labels[:]
+		if k.desc == processesByUserDesc || k.desc == processesByHostDesc {
+			labels = labels[:1]
+		}
+		ch <- prom.MustNewConstMetric(k.desc, prom.GaugeValue, sum[k], labels...)
+	}
+}
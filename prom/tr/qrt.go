@@ -0,0 +1,74 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	"strconv"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// QRT translates the percona.response-time domain to mysqld_exporter
+// info_schema.query_response_time metrics. The histogram requires collector
+// option histogram=yes.
+type QRT struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr QRT) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// Copied from /percona/mysqld_exporter/collector/info_schema_query_response_time.go
+var infoSchemaQueryResponseTimeDesc = prom.NewDesc(
+	prom.BuildFQName("mysql", "info_schema", "query_response_time_seconds"),
+	"The number of all queries by duration they took to execute.",
+	[]string{}, nil,
+)
+
+// Percentiles are not reported by mysqld_exporter, but they're the default
+// percona.response-time metrics, so they're reported with a similar name.
+var infoSchemaQueryResponseTimePercentileDesc = prom.NewDesc(
+	prom.BuildFQName("mysql", "info_schema", "query_response_time_percentile_seconds"),
+	"Query response time percentile.",
+	[]string{"percentile"}, nil,
+)
+
+func (tr QRT) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	var count, sum float64
+	buckets := map[float64]uint64{}
+	for i := range values {
+		switch values[i].Name {
+		case "response_time":
+			for k := range values[i].Meta {
+				if !strings.HasPrefix(k, "p") {
+					continue
+				}
+				ch <- prom.MustNewConstMetric(
+					infoSchemaQueryResponseTimePercentileDesc,
+					prom.GaugeValue,
+					values[i].Value/1000000, // microseconds to seconds
+					k,
+				)
+			}
+		case "response_time_bucket":
+			le, err := strconv.ParseFloat(values[i].Group["le"], 64)
+			if err != nil {
+				continue
+			}
+			buckets[le] = uint64(values[i].Value)
+		case "response_time_count":
+			count = values[i].Value
+		case "response_time_sum":
+			sum = values[i].Value
+		}
+	}
+	if len(buckets) == 0 {
+		return
+	}
+	ch <- prom.MustNewConstHistogram(infoSchemaQueryResponseTimeDesc, uint64(count), sum, buckets)
+}
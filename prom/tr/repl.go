@@ -0,0 +1,74 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// Repl translates the repl domain to mysqld_exporter slave_status metrics:
+// mysql_slave_status_<column> where column is the lowercase SHOW SLAVE STATUS
+// column name.
+type Repl struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr Repl) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// Blip repl metric name => SHOW SLAVE STATUS column name (lowercase). Blip
+// metrics not listed (running and gtid_gap) don't have a column, so they're
+// reported by Blip name: mysql_slave_status_gtid_gap.
+var replColumn = map[string]string{
+	"io_running":          "slave_io_running",
+	"sql_running":         "slave_sql_running",
+	"seconds_behind":      "seconds_behind_master",
+	"relay_log_space":     "relay_log_space",
+	"read_source_log_pos": "read_master_log_pos",
+	"exec_source_log_pos": "exec_master_log_pos",
+	"relay_log_pos":       "relay_log_pos",
+	"sql_delay":           "sql_delay",
+	"last_errno":          "last_errno",
+	"last_io_errno":       "last_io_errno",
+}
+
+// Copied from /percona/mysqld_exporter/collector/slave_status.go
+var replLabels = []string{"master_host", "master_uuid", "channel_name", "connection_name"}
+
+func (tr Repl) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	for i := range values {
+		if values[i].Name == "running" && values[i].Value == -1 {
+			continue // not a replica; mysqld_exporter reports nothing
+		}
+		name, ok := replColumn[values[i].Name]
+		if !ok {
+			name = validPrometheusName(values[i].Name)
+		}
+		labels := []string{
+			values[i].Meta["source_host"],
+			values[i].Meta["source_uuid"],
+			values[i].Group["channel"],
+			"", // connection_name (MariaDB)
+		}
+		names := []string{name}
+		if name == "last_errno" {
+			names = append(names, "last_sql_errno") // same value
+		}
+		for _, name := range names {
+			ch <- prom.MustNewConstMetric(
+				prom.NewDesc(
+					prom.BuildFQName(GENERIC_PREFIX, tr.Domain, name),
+					"Generic metric from SHOW SLAVE STATUS.",
+					replLabels, nil,
+				),
+				prom.UntypedValue,
+				values[i].Value,
+				labels...,
+			)
+		}
+	}
+}
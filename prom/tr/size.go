@@ -0,0 +1,90 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cashapp/blip"
+)
+
+// TableSize translates the size.table domain to mysqld_exporter info_schema.tables
+// metrics.
+type TableSize struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr TableSize) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+// Copied from /percona/mysqld_exporter/collector/info_schema_tables.go
+var (
+	infoSchemaTablesSizeDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "table_size"),
+		"The size of the table components from information_schema.tables",
+		[]string{"schema", "table", "component"}, nil,
+	)
+	infoSchemaTablesRowsDesc = prom.NewDesc(
+		prom.BuildFQName("mysql", "info_schema", "table_rows"),
+		"The estimated number of rows in the table from information_schema.tables",
+		[]string{"schema", "table"}, nil,
+	)
+)
+
+// Blip size.table metric name => mysqld_exporter component label value
+var tableSizeComponent = map[string]string{
+	"data_bytes":  "data_length",
+	"index_bytes": "index_length",
+	"free_bytes":  "data_free",
+}
+
+func (tr TableSize) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	for i := range values {
+		db, tbl := values[i].Group["db"], values[i].Group["tbl"]
+		if values[i].Name == "rows" {
+			ch <- prom.MustNewConstMetric(
+				infoSchemaTablesRowsDesc, prom.GaugeValue, values[i].Value, db, tbl,
+			)
+			continue
+		}
+		component, ok := tableSizeComponent[values[i].Name]
+		if !ok {
+			continue
+		}
+		ch <- prom.MustNewConstMetric(
+			infoSchemaTablesSizeDesc, prom.GaugeValue, values[i].Value, db, tbl, component,
+		)
+	}
+}
+
+// DatabaseSize translates the size.database domain. mysqld_exporter does not have
+// an equivalent metric (database size is the sum of mysql_info_schema_table_size),
+// so it uses the same naming convention: mysql_info_schema_database_size_bytes.
+type DatabaseSize struct {
+	Domain      string
+	ShortDomain string
+}
+
+func (tr DatabaseSize) Names() (string, string, string) {
+	return GENERIC_PREFIX, tr.Domain, tr.ShortDomain
+}
+
+var infoSchemaDatabaseSizeDesc = prom.NewDesc(
+	prom.BuildFQName("mysql", "info_schema", "database_size_bytes"),
+	"The size of the database (data and index) from information_schema.tables",
+	[]string{"schema"}, nil,
+)
+
+func (tr DatabaseSize) Translate(values []blip.MetricValue, ch chan<- prom.Metric) {
+	for i := range values {
+		db := values[i].Group["db"]
+		if values[i].Name != "bytes" || db == "" {
+			continue // "" = total, which Prometheus can sum
+		}
+		ch <- prom.MustNewConstMetric(
+			infoSchemaDatabaseSizeDesc, prom.GaugeValue, values[i].Value, db,
+		)
+	}
+}
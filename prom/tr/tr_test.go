@@ -0,0 +1,144 @@
+// Copyright 2022 Block, Inc.
+
+package tr_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/prom/tr"
+)
+
+type translator interface {
+	Translate(values []blip.MetricValue, ch chan<- prometheus.Metric)
+}
+
+// collector is an unchecked Prometheus collector that translates values
+type collector struct {
+	tr     translator
+	values []blip.MetricValue
+}
+
+func (c collector) Describe(descs chan<- *prometheus.Desc) {}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	c.tr.Translate(c.values, ch)
+}
+
+// scrape returns the translated values in Prometheus exposition format.
+func scrape(t *testing.T, tr translator, values []blip.MetricValue) string {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector{tr: tr, values: values})
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		expfmt.MetricFamilyToText(&buf, mf)
+	}
+	return buf.String()
+}
+
+func TestRepl(t *testing.T) {
+	meta := map[string]string{"source_host": "db1", "source_uuid": "abc"}
+	group := map[string]string{"channel": ""}
+	got := scrape(t, tr.Repl{Domain: "slave_status"}, []blip.MetricValue{
+		{Name: "sql_running", Type: blip.GAUGE, Value: 1, Group: group, Meta: meta},
+		{Name: "seconds_behind", Type: blip.GAUGE, Value: 3, Group: group, Meta: meta},
+		{Name: "exec_source_log_pos", Type: blip.GAUGE, Value: 1024, Group: group, Meta: meta},
+	})
+	expect := `# HELP mysql_slave_status_exec_master_log_pos Generic metric from SHOW SLAVE STATUS.
+# TYPE mysql_slave_status_exec_master_log_pos untyped
+mysql_slave_status_exec_master_log_pos{channel_name="",connection_name="",master_host="db1",master_uuid="abc"} 1024
+# HELP mysql_slave_status_seconds_behind_master Generic metric from SHOW SLAVE STATUS.
+# TYPE mysql_slave_status_seconds_behind_master untyped
+mysql_slave_status_seconds_behind_master{channel_name="",connection_name="",master_host="db1",master_uuid="abc"} 3
+# HELP mysql_slave_status_slave_sql_running Generic metric from SHOW SLAVE STATUS.
+# TYPE mysql_slave_status_slave_sql_running untyped
+mysql_slave_status_slave_sql_running{channel_name="",connection_name="",master_host="db1",master_uuid="abc"} 1
+`
+	if got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expect)
+	}
+
+	// Not a replica: nothing reported, like mysqld_exporter
+	got = scrape(t, tr.Repl{Domain: "slave_status"}, []blip.MetricValue{
+		{Name: "running", Type: blip.GAUGE, Value: -1, Group: group},
+	})
+	if got != "" {
+		t.Errorf("got metrics for not a replica, expected none:\n%s", got)
+	}
+}
+
+func TestProcesslist(t *testing.T) {
+	got := scrape(t, tr.Processlist{Domain: "info_schema"}, []blip.MetricValue{
+		{Name: "threads", Type: blip.GAUGE, Value: 2, Group: map[string]string{"command": "Query", "state": "Sending data"}},
+		{Name: "threads", Type: blip.GAUGE, Value: 3, Group: map[string]string{"command": "query", "state": "sending-data"}},
+		{Name: "threads", Type: blip.GAUGE, Value: 1, Group: map[string]string{"command": "Sleep", "state": ""}},
+		{Name: "processes_by_user", Type: blip.GAUGE, Value: 6, Group: map[string]string{"user": "app"}},
+	})
+	expect := `# HELP mysql_info_schema_processlist_processes_by_user The number of processes by user.
+# TYPE mysql_info_schema_processlist_processes_by_user gauge
+mysql_info_schema_processlist_processes_by_user{mysql_user="app"} 6
+# HELP mysql_info_schema_processlist_threads The number of threads split by current state.
+# TYPE mysql_info_schema_processlist_threads gauge
+mysql_info_schema_processlist_threads{command="query",state="sending_data"} 5
+mysql_info_schema_processlist_threads{command="sleep",state="unknown"} 1
+`
+	if got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
+func TestQRT(t *testing.T) {
+	got := scrape(t, tr.QRT{Domain: "info_schema"}, []blip.MetricValue{
+		{Name: "response_time", Type: blip.GAUGE, Value: 500000, Meta: map[string]string{"p999": "0.998"}},
+		{Name: "response_time_bucket", Type: blip.COUNTER, Value: 2, Group: map[string]string{"le": "0.1"}},
+		{Name: "response_time_bucket", Type: blip.COUNTER, Value: 5, Group: map[string]string{"le": "1"}},
+		{Name: "response_time_count", Type: blip.COUNTER, Value: 5},
+		{Name: "response_time_sum", Type: blip.COUNTER, Value: 1.5},
+	})
+	expect := `# HELP mysql_info_schema_query_response_time_percentile_seconds Query response time percentile.
+# TYPE mysql_info_schema_query_response_time_percentile_seconds gauge
+mysql_info_schema_query_response_time_percentile_seconds{percentile="p999"} 0.5
+# HELP mysql_info_schema_query_response_time_seconds The number of all queries by duration they took to execute.
+# TYPE mysql_info_schema_query_response_time_seconds histogram
+mysql_info_schema_query_response_time_seconds_bucket{le="0.1"} 2
+mysql_info_schema_query_response_time_seconds_bucket{le="1"} 5
+mysql_info_schema_query_response_time_seconds_bucket{le="+Inf"} 5
+mysql_info_schema_query_response_time_seconds_sum 1.5
+mysql_info_schema_query_response_time_seconds_count 5
+`
+	if got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
+func TestBlip(t *testing.T) {
+	got := scrape(t, tr.Blip{Domain: "blip"}, []blip.MetricValue{
+		{Name: "collect_all", Type: blip.COUNTER, Value: 9},
+		{Name: "domain_collect_latency", Type: blip.GAUGE, Value: 1.5, Group: map[string]string{"domain": "status.global"}},
+		{Name: "domain_collect_latency", Type: blip.GAUGE, Value: 2.5, Group: map[string]string{"domain": "repl"}},
+		{Name: "sink_send_errors", Type: blip.COUNTER, Value: 1, Group: map[string]string{"sink": "statsd"}},
+	})
+	expect := `# HELP mysql_blip_collect_all Blip counter metric.
+# TYPE mysql_blip_collect_all counter
+mysql_blip_collect_all 9
+# HELP mysql_blip_domain_collect_latency Blip gauge metric.
+# TYPE mysql_blip_domain_collect_latency gauge
+mysql_blip_domain_collect_latency{domain="repl"} 2.5
+mysql_blip_domain_collect_latency{domain="status.global"} 1.5
+# HELP mysql_blip_sink_send_errors Blip counter metric.
+# TYPE mysql_blip_sink_send_errors counter
+mysql_blip_sink_send_errors{sink="statsd"} 1
+`
+	if got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/metrics"
 	"github.com/cashapp/blip/monitor"
+	"github.com/cashapp/blip/prom"
 	"github.com/cashapp/blip/proto"
 	"github.com/cashapp/blip/sink"
 	"github.com/cashapp/blip/status"
@@ -40,6 +41,7 @@ func NewAPI(cfg blip.ConfigAPI, ml *monitor.Loader) *API {
 	mux.HandleFunc("/registered", api.registered)
 	mux.HandleFunc("/monitors/stop", api.monitorsStop)
 	mux.HandleFunc("/monitors/restart", api.monitorsRestart)
+	mux.HandleFunc("/metrics", prom.SharedHandler(ml.Exporters, cfg.ExporterMerge))
 
 	api.httpServer = &http.Server{
 		Addr:    cfg.Bind,
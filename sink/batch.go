@@ -0,0 +1,528 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	DEFAULT_BATCH_MAX_SIZE  = 10000
+	DEFAULT_BATCH_MAX_BYTES = "5M"
+	DEFAULT_BATCH_TIMEOUT   = "5s"
+)
+
+// BatchItem is metrics from one monitor in a batch.
+type BatchItem struct {
+	Metrics *blip.Metrics
+	Tags    map[string]string // monitor tags
+}
+
+// BatchSender is implemented by sinks that can send metrics from many monitors
+// in one request. The sink is made for the first monitor, but SendBatch must use
+// BatchItem.Tags, not the tags the sink was made with. If some items cannot be
+// sent but the rest were sent, SendBatch returns BatchItemErrors.
+type BatchSender interface {
+	SendBatch(ctx context.Context, items []BatchItem) error
+}
+
+// BatchItemErrors is returned by BatchSender.SendBatch when items from some
+// monitors were not sent (for example, invalid metrics) but the rest of the
+// batch was sent. The batch is not sent again.
+type BatchItemErrors map[string]error // keyed on monitor ID
+
+func (e BatchItemErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msg := make([]string, len(ids))
+	for i, id := range ids {
+		msg[i] = id + ": " + e[id].Error()
+	}
+	return strings.Join(msg, "; ")
+}
+
+// BatchArgs are the batch options for a sink.
+type BatchArgs struct {
+	Interval time.Duration // batch-interval (required)
+	MaxSize  int           // batch-max-size: max metric values per batch
+	MaxBytes int64         // batch-max-bytes: approximate max bytes per batch
+	Timeout  time.Duration // send-timeout
+	// On send error, wait RetryWait, 2x, 4x, and so on up to MaxRetryWait
+	// (with jitter) before sending again
+	RetryWait    time.Duration // send-retry-wait
+	MaxRetryWait time.Duration // send-retry-max-wait
+}
+
+// batcher coalesces metrics from many monitors and sends them in one request
+// per interval, or sooner if a batch reaches max size or bytes. There is one
+// batcher per sink name and options, shared by all monitors that use the sink
+// with the same options. If a send fails, the batch is kept and sent with the
+// next batch, up to max size and bytes; the oldest metrics are dropped. After
+// a failed send, the batcher backs off like Retry: it does not send again until
+// the backoff wait has passed, even if the batch is full.
+type batcher struct {
+	key  string
+	name string
+	sink BatchSender
+	args BatchArgs
+	// --
+	*sync.Mutex
+	items     []BatchItem
+	size      int               // metric values in items
+	bytes     int64             // approximate bytes in items
+	refs      int               // number of monitors using the batcher
+	failures  int               // consecutive send errors
+	nextSend  time.Time         // backoff: no send before this time
+	errors    uint64            // total send errors
+	dropped   map[string]uint64 // metrics dropped per monitor ID
+	lastErr   error
+	lastErrTs time.Time
+	flushNow  chan struct{}
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+var batchers = map[string]*batcher{}
+var batchersMux = &sync.Mutex{}
+
+// batchKey returns a key unique to the sink name and options, excluding options
+// that are unique to each monitor (none for built-in sinks after interpolation).
+func batchKey(sinkName string, opts map[string]string) string {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(sinkName)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + opts[k])
+	}
+	return b.String()
+}
+
+// batchSinkFor returns a sink for the monitor that adds metrics to the batcher
+// for the sink name and options. If the batcher does not exist, it's created
+// using makeSink to make the real sink.
+func batchSinkFor(args blip.SinkFactoryArgs, bargs BatchArgs, makeSink func() (BatchSender, error)) (*batchSink, error) {
+	b, err := acquireBatcher(args, bargs, makeSink)
+	if err != nil {
+		return nil, err
+	}
+	return &batchSink{
+		args:     args,
+		bargs:    bargs,
+		makeSink: makeSink,
+		Mutex:    &sync.Mutex{},
+		b:        b,
+	}, nil
+}
+
+// acquireBatcher returns the batcher for the sink name and options, creating
+// it if needed, and increments its reference count.
+func acquireBatcher(args blip.SinkFactoryArgs, bargs BatchArgs, makeSink func() (BatchSender, error)) (*batcher, error) {
+	key := batchKey(args.SinkName, args.Options)
+
+	batchersMux.Lock()
+	defer batchersMux.Unlock()
+	b, ok := batchers[key]
+	if !ok {
+		sink, err := makeSink()
+		if err != nil {
+			return nil, err
+		}
+		b = &batcher{
+			key:      key,
+			name:     args.SinkName,
+			sink:     sink,
+			args:     bargs,
+			Mutex:    &sync.Mutex{},
+			dropped:  map[string]uint64{},
+			flushNow: make(chan struct{}, 1),
+			stopChan: make(chan struct{}),
+			doneChan: make(chan struct{}),
+		}
+		batchers[key] = b
+		go b.run()
+		blip.Debug("new %s batcher: %+v", args.SinkName, bargs)
+	}
+	b.Lock()
+	b.refs++
+	b.Unlock()
+	return b, nil
+}
+
+func (b *batcher) add(item BatchItem) {
+	size, bytes := batchItemSize(item)
+	b.Lock()
+	b.items = append(b.items, item)
+	b.size += size
+	b.bytes += bytes
+	full := b.size >= b.args.MaxSize || b.bytes >= b.args.MaxBytes
+	if full && b.failures > 0 {
+		// Backing off after send error: keep only the latest metrics that fit
+		// one batch, like requeue, rather than growing until the sink recovers
+		b.trim()
+	}
+	b.Unlock()
+	if full {
+		select {
+		case b.flushNow <- struct{}{}:
+		default: // flush already pending
+		}
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.doneChan)
+	ticker := time.NewTicker(b.args.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.flushNow:
+		case <-b.stopChan:
+			b.flush() // last try, even if backing off
+			return
+		}
+		if b.ready() {
+			b.flush()
+		}
+	}
+}
+
+// ready returns false if the batcher is backing off after a send error.
+func (b *batcher) ready() bool {
+	b.Lock()
+	defer b.Unlock()
+	return !time.Now().Before(b.nextSend)
+}
+
+// flush sends all batched metrics, in one or more batches no larger than max
+// size and bytes.
+func (b *batcher) flush() {
+	b.Lock()
+	pending := b.items
+	b.items = nil
+	b.size = 0
+	b.bytes = 0
+	b.Unlock()
+
+	for len(pending) > 0 {
+		// Take items up to max size/bytes; always at least one item
+		n, size, bytes := 0, 0, int64(0)
+		for n < len(pending) {
+			s, by := batchItemSize(pending[n])
+			if n > 0 && (size+s > b.args.MaxSize || bytes+by > b.args.MaxBytes) {
+				break
+			}
+			size += s
+			bytes += by
+			n++
+		}
+		batch := pending[:n]
+		pending = pending[n:]
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.args.Timeout)
+		err := b.sink.SendBatch(ctx, batch)
+		cancel()
+		b.report(batch, size, err)
+		if _, ok := err.(BatchItemErrors); ok {
+			err = nil // rest of batch sent; invalid items are not sent again
+		}
+		b.sent(err)
+		if err != nil {
+			b.requeue(append(batch, pending...))
+			return // try again next flush after backoff
+		}
+	}
+}
+
+// sent updates backoff state after sending a batch.
+func (b *batcher) sent(err error) {
+	b.Lock()
+	defer b.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.nextSend = time.Time{}
+		return
+	}
+	now := time.Now()
+	b.errors++
+	b.failures++
+	b.lastErr = err
+	b.lastErrTs = now
+
+	// Exponential backoff with jitter, like Retry.send
+	wait := b.args.MaxRetryWait
+	if shift := b.failures - 1; shift < 32 {
+		if d := b.args.RetryWait << shift; d > 0 && d < wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		b.nextSend = now.Add(jitter(wait))
+	}
+}
+
+// requeue puts unsent items before newly added items, then drops the oldest
+// items until the batch is within max size and bytes.
+func (b *batcher) requeue(unsent []BatchItem) {
+	b.Lock()
+	defer b.Unlock()
+	b.items = append(unsent, b.items...)
+	b.size, b.bytes = 0, 0
+	for _, item := range b.items {
+		s, by := batchItemSize(item)
+		b.size += s
+		b.bytes += by
+	}
+	b.trim()
+}
+
+// trim drops the oldest items until the batch is within max size and bytes,
+// and reports the dropped metrics for each monitor. The caller must lock b.
+func (b *batcher) trim() {
+	dropped := map[string]int{}
+	for len(b.items) > 1 && (b.size > b.args.MaxSize || b.bytes > b.args.MaxBytes) {
+		s, by := batchItemSize(b.items[0])
+		b.size -= s
+		b.bytes -= by
+		dropped[b.items[0].Metrics.MonitorId]++
+		b.items = b.items[1:]
+	}
+	for monitorId, n := range dropped {
+		b.dropped[monitorId] += uint64(n)
+		status.Monitor(monitorId, b.name, "batch full after send errors, dropped %d oldest metrics at %s", n, time.Now())
+		event.MonitorReceiver{MonitorId: monitorId}.Errorf(event.SINK_SEND_ERROR, "%s batch full after send errors, dropped %d oldest metrics", b.name, n)
+	}
+}
+
+// report sets status and sends events for each monitor in the batch.
+func (b *batcher) report(batch []BatchItem, size int, err error) {
+	seen := map[string]bool{}
+	for _, item := range batch {
+		monitorId := item.Metrics.MonitorId
+		if seen[monitorId] {
+			continue
+		}
+		seen[monitorId] = true
+		if itemErrs, ok := err.(BatchItemErrors); ok {
+			if itemErr, ok := itemErrs[monitorId]; ok {
+				status.Monitor(monitorId, b.name, "error on last batch send at %s: %s", time.Now(), itemErr)
+				event.MonitorReceiver{MonitorId: monitorId}.Errorf(event.SINK_SEND_ERROR, itemErr.Error())
+				continue
+			}
+			status.Monitor(monitorId, b.name, "last sent batch of %d metrics from %d monitors at %s", size, len(batch), time.Now())
+			continue
+		}
+		if err == nil {
+			status.Monitor(monitorId, b.name, "last sent batch of %d metrics from %d monitors at %s", size, len(batch), time.Now())
+		} else {
+			status.Monitor(monitorId, b.name, "error on last batch send at %s: %s", time.Now(), err)
+			event.MonitorReceiver{MonitorId: monitorId}.Errorf(event.SINK_SEND_ERROR, err.Error())
+		}
+	}
+}
+
+// release decrements the batcher reference count. When no monitors use the
+// batcher, it's flushed, stopped, and removed.
+func (b *batcher) release() {
+	batchersMux.Lock()
+	b.Lock()
+	b.refs--
+	last := b.refs == 0
+	b.Unlock()
+	if last {
+		delete(batchers, b.key)
+	}
+	batchersMux.Unlock()
+
+	if last {
+		close(b.stopChan)
+		<-b.doneChan
+	}
+}
+
+// batchItemSize returns the number of metric values in the item and their
+// approximate size in bytes: names, group keys and values, tags, and 16 bytes
+// for the value and timestamp.
+func batchItemSize(item BatchItem) (int, int64) {
+	var tagBytes int64
+	for k, v := range item.Tags {
+		tagBytes += int64(len(k) + len(v))
+	}
+	n := 0
+	var bytes int64
+	for domain, metrics := range item.Metrics.Values {
+		for i := range metrics {
+			bytes += int64(len(domain)+len(metrics[i].Name)+16) + tagBytes
+			for k, v := range metrics[i].Group {
+				bytes += int64(len(k) + len(v))
+			}
+		}
+		n += len(metrics)
+	}
+	return n, bytes
+}
+
+// --------------------------------------------------------------------------
+
+// batchSink is the sink for one monitor that adds metrics to a shared batcher.
+type batchSink struct {
+	args     blip.SinkFactoryArgs
+	bargs    BatchArgs
+	makeSink func() (BatchSender, error)
+	// --
+	*sync.Mutex
+	b *batcher // nil after Stop
+}
+
+var _ blip.Sink = &batchSink{}
+var _ blip.SinkStopper = &batchSink{}
+var _ blip.SinkErrorReporter = &batchSink{}
+
+// Send adds the metrics to the batch and returns immediately. Send errors are
+// reported in monitor status and events when the batch is sent. If the sink
+// was stopped (the monitor restarted), the batcher is acquired again.
+func (s *batchSink) Send(ctx context.Context, m *blip.Metrics) error {
+	if m.MonitorId == "" { // used by batcher.report
+		m2 := *m
+		m2.MonitorId = s.args.MonitorId
+		m = &m2
+	}
+
+	// Add while locked so a concurrent Stop can't release (and stop) the
+	// batcher before the metrics are added, else they would not be sent
+	s.Lock()
+	defer s.Unlock()
+	if s.b == nil {
+		b, err := acquireBatcher(s.args, s.bargs, s.makeSink)
+		if err != nil {
+			return err
+		}
+		s.b = b
+	}
+	s.b.add(BatchItem{Metrics: m, Tags: s.args.Tags})
+	return nil
+}
+
+// SinkError returns the last batch send error and the number of metrics from
+// this monitor that were dropped. It implements blip.SinkErrorReporter.
+func (s *batchSink) SinkError() string {
+	s.Lock()
+	b := s.b
+	s.Unlock()
+	if b == nil {
+		return ""
+	}
+	b.Lock()
+	defer b.Unlock()
+	msg := []string{}
+	if n := b.dropped[s.args.MonitorId]; n > 0 {
+		msg = append(msg, fmt.Sprintf("%d dropped", n))
+	}
+	if b.failures > 0 {
+		msg = append(msg, fmt.Sprintf("%d consecutive batch errors, last at %s: %s", b.failures, b.lastErrTs.Format(time.RFC3339), b.lastErr))
+	}
+	return strings.Join(msg, "; ")
+}
+
+// BlipMetrics returns batch metrics for the blip domain. Errors and buffer
+// depth are for the batch shared by all monitors; dropped metrics are for this
+// monitor. It implements blipmetrics.Source.
+func (s *batchSink) BlipMetrics() []blip.MetricValue {
+	s.Lock()
+	b := s.b
+	s.Unlock()
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+	group := map[string]string{"sink": s.args.SinkName}
+	return []blip.MetricValue{
+		{Name: "sink_retry_errors", Type: blip.COUNTER, Value: float64(b.errors), Group: group},
+		{Name: "sink_buffer_depth", Type: blip.GAUGE, Value: float64(len(b.items)), Group: group},
+		{Name: "sink_dropped", Type: blip.COUNTER, Value: float64(b.dropped[s.args.MonitorId]), Group: group},
+	}
+}
+
+func (s *batchSink) Name() string {
+	return s.args.SinkName
+}
+
+// Stop releases the batcher, which sends pending metrics if this is the last
+// monitor using it.
+func (s *batchSink) Stop() {
+	s.Lock()
+	b := s.b
+	s.b = nil
+	s.Unlock()
+	if b != nil {
+		b.release()
+	}
+}
+
+// parseBatchArgs parses the batch options. It returns false if batching is
+// not enabled (option batch-interval is not set).
+func parseBatchArgs(opts map[string]string) (BatchArgs, bool, error) {
+	v, ok := opts["batch-interval"]
+	if !ok || v == "" {
+		return BatchArgs{}, false, nil
+	}
+	args := BatchArgs{MaxSize: DEFAULT_BATCH_MAX_SIZE}
+	var err error
+	args.Interval, err = time.ParseDuration(v)
+	if err != nil {
+		return args, false, fmt.Errorf("invalid batch-interval: %s: %s", v, err)
+	}
+	if args.Interval <= 0 {
+		return args, false, fmt.Errorf("invalid batch-interval: %s: must be greater than zero", v)
+	}
+	if v, ok := opts["batch-max-size"]; ok {
+		args.MaxSize, err = strconv.Atoi(v)
+		if err != nil {
+			return args, false, fmt.Errorf("invalid batch-max-size: %s: %s", v, err)
+		}
+		if args.MaxSize <= 0 {
+			return args, false, fmt.Errorf("invalid batch-max-size: %s: must be greater than zero", v)
+		}
+	}
+	args.MaxBytes, err = ParseBytes(blip.SetOrDefault(opts["batch-max-bytes"], DEFAULT_BATCH_MAX_BYTES))
+	if err != nil {
+		return args, false, fmt.Errorf("invalid batch-max-bytes: %s", err)
+	}
+	timeout := blip.SetOrDefault(opts["send-timeout"], DEFAULT_BATCH_TIMEOUT)
+	args.Timeout, err = time.ParseDuration(timeout)
+	if err != nil {
+		return args, false, fmt.Errorf("invalid send-timeout: %s: %s", timeout, err)
+	}
+	wait := blip.SetOrDefault(opts["send-retry-wait"], DEFAULT_RETRY_SEND_RETRY_WAIT)
+	args.RetryWait, err = time.ParseDuration(wait)
+	if err != nil {
+		return args, false, fmt.Errorf("invalid send-retry-wait: %s: %s", wait, err)
+	}
+	maxWait := blip.SetOrDefault(opts["send-retry-max-wait"], DEFAULT_RETRY_SEND_RETRY_MAX_WAIT)
+	args.MaxRetryWait, err = time.ParseDuration(maxWait)
+	if err != nil {
+		return args, false, fmt.Errorf("invalid send-retry-max-wait: %s: %s", maxWait, err)
+	}
+	if args.MaxRetryWait < args.RetryWait {
+		args.MaxRetryWait = args.RetryWait
+	}
+	return args, true, nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+type mockBatchSender struct {
+	*sync.Mutex
+	batches [][]BatchItem
+	err     error
+	sent    chan struct{}
+}
+
+func newMockBatchSender() *mockBatchSender {
+	return &mockBatchSender{
+		Mutex: &sync.Mutex{},
+		sent:  make(chan struct{}, 10),
+	}
+}
+
+func (s *mockBatchSender) SendBatch(ctx context.Context, items []BatchItem) error {
+	s.Lock()
+	defer s.Unlock()
+	defer func() { s.sent <- struct{}{} }()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, items)
+	return nil
+}
+
+// monitors returns the monitor IDs in each batch sent.
+func (s *mockBatchSender) monitors() [][]string {
+	s.Lock()
+	defer s.Unlock()
+	ids := [][]string{}
+	for _, batch := range s.batches {
+		b := []string{}
+		for _, item := range batch {
+			b = append(b, item.Metrics.MonitorId+":"+item.Tags["env"])
+		}
+		ids = append(ids, b)
+	}
+	return ids
+}
+
+func TestBatchSharedSink(t *testing.T) {
+	mockSender := newMockBatchSender()
+	made := 0
+	makeSink := func() (BatchSender, error) {
+		made++
+		return mockSender, nil
+	}
+	bargs := BatchArgs{Interval: time.Hour, MaxSize: 100, MaxBytes: 1024 * 1024, Timeout: time.Second}
+	opts := map[string]string{"url": "http://localhost"}
+
+	s1, err := batchSinkFor(blip.SinkFactoryArgs{SinkName: "test-batch", MonitorId: "m1", Options: opts, Tags: map[string]string{"env": "prod"}}, bargs, makeSink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := batchSinkFor(blip.SinkFactoryArgs{SinkName: "test-batch", MonitorId: "m2", Options: opts, Tags: map[string]string{"env": "dev"}}, bargs, makeSink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if made != 1 {
+		t.Errorf("made %d sinks, expected 1", made)
+	}
+	b := s1.b
+	if b != s2.b {
+		t.Errorf("monitors have different batchers, expected same batcher")
+	}
+
+	s1.Send(context.Background(), testMetrics())
+	s2.Send(context.Background(), testMetrics())
+
+	// Batch is sent when the last monitor stops
+	s1.Stop()
+	if got := mockSender.monitors(); len(got) != 0 {
+		t.Errorf("batch sent after first Stop: %v", got)
+	}
+	s2.Stop()
+	if diff := deep.Equal(mockSender.monitors(), [][]string{{"m1:prod", "m2:dev"}}); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := batchers[b.key]; ok {
+		t.Errorf("batcher not removed after last Stop")
+	}
+
+	// Monitor restarted: Send acquires a new batcher
+	s1.Send(context.Background(), testMetrics())
+	if s1.b == nil || s1.b == b {
+		t.Errorf("Send after Stop did not acquire a new batcher")
+	}
+	if made != 2 {
+		t.Errorf("made %d sinks, expected 2", made)
+	}
+	s1.Stop()
+}
+
+func TestBatchMaxSize(t *testing.T) {
+	mockSender := newMockBatchSender()
+	bargs := BatchArgs{Interval: time.Hour, MaxSize: 8, MaxBytes: 1024 * 1024, Timeout: time.Second}
+	args := blip.SinkFactoryArgs{SinkName: "test-batch-size", MonitorId: "m1"}
+	s, err := batchSinkFor(args, bargs, func() (BatchSender, error) { return mockSender, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// testMetrics has 5 values, so second Send reaches max size and triggers
+	// a flush before the interval
+	s.Send(context.Background(), testMetrics())
+	s.Send(context.Background(), testMetrics())
+	select {
+	case <-mockSender.sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for flush on max size")
+	}
+
+	// 10 values > max size 8, so the flush sends two batches
+	<-mockSender.sent
+	if diff := deep.Equal(mockSender.monitors(), [][]string{{"m1:"}, {"m1:"}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestBatchRequeue(t *testing.T) {
+	mockSender := newMockBatchSender()
+	mockSender.err = fmt.Errorf("sink down")
+	bargs := BatchArgs{Interval: time.Hour, MaxSize: 11, MaxBytes: 1024 * 1024, Timeout: time.Second}
+	args := blip.SinkFactoryArgs{SinkName: "test-batch-requeue", MonitorId: "m1"}
+	s, err := batchSinkFor(args, bargs, func() (BatchSender, error) { return mockSender, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.b
+
+	// Send fails, so metrics are kept
+	for i := 0; i < 2; i++ {
+		m := testMetrics()
+		m.Level = fmt.Sprintf("%d", i)
+		s.Send(context.Background(), m)
+	}
+	b.flush()
+	if len(b.items) != 2 {
+		t.Errorf("%d items after failed send, expected 2", len(b.items))
+	}
+
+	// Third metrics exceeds max size 11, so oldest (0) is dropped
+	m := testMetrics()
+	m.Level = "2"
+	b.Lock()
+	b.items = append(b.items, BatchItem{Metrics: m})
+	b.Unlock()
+	b.flush()
+	got := []string{}
+	for _, item := range b.items {
+		got = append(got, item.Metrics.Level)
+	}
+	if diff := deep.Equal(got, []string{"1", "2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Sink recovers
+	mockSender.Lock()
+	mockSender.err = nil
+	mockSender.Unlock()
+	s.Stop()
+	if len(b.items) != 0 {
+		t.Errorf("%d items after Stop, expected 0", len(b.items))
+	}
+	if n := len(mockSender.monitors()); n != 1 {
+		t.Errorf("%d batches sent, expected 1", n)
+	}
+}
+
+func TestBatchBackoff(t *testing.T) {
+	mockSender := newMockBatchSender()
+	mockSender.err = fmt.Errorf("rate limited")
+	bargs := BatchArgs{Interval: time.Hour, MaxSize: 5, MaxBytes: 1024 * 1024, Timeout: time.Second,
+		RetryWait: time.Hour, MaxRetryWait: time.Hour}
+	args := blip.SinkFactoryArgs{SinkName: "test-batch-backoff", MonitorId: "m1"}
+	s, err := batchSinkFor(args, bargs, func() (BatchSender, error) { return mockSender, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.b
+
+	// testMetrics has 5 values = max size, so each Send fills the batch. First
+	// flush fails, then the batcher backs off: it doesn't send again even
+	// though the batch is full, and it keeps only the latest metrics
+	s.Send(context.Background(), testMetrics())
+	select {
+	case <-mockSender.sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for flush on max size")
+	}
+	for i := 0; i < 3; i++ {
+		s.Send(context.Background(), testMetrics())
+	}
+	select {
+	case <-mockSender.sent:
+		t.Fatal("batch sent during backoff")
+	case <-time.After(100 * time.Millisecond):
+	}
+	b.Lock()
+	n := len(b.items)
+	b.Unlock()
+	if n != 1 {
+		t.Errorf("%d items during backoff, expected 1", n)
+	}
+
+	status := s.SinkError()
+	for _, str := range []string{"3 dropped", "1 consecutive batch errors", "rate limited"} {
+		if !strings.Contains(status, str) {
+			t.Errorf("SinkError %q does not contain %q", status, str)
+		}
+	}
+	got := map[string]float64{}
+	for _, v := range s.BlipMetrics() {
+		got[v.Name] = v.Value
+	}
+	expect := map[string]float64{"sink_retry_errors": 1, "sink_buffer_depth": 1, "sink_dropped": 3}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	mockSender.Lock()
+	mockSender.err = nil
+	mockSender.Unlock()
+	s.Stop() // last flush even if backing off
+	if n := len(mockSender.monitors()); n != 1 {
+		t.Errorf("%d batches sent, expected 1", n)
+	}
+}
+
+func TestBatchItemErrors(t *testing.T) {
+	mockSender := newMockBatchSender()
+	mockSender.err = BatchItemErrors{"m1": fmt.Errorf("no Blip metrics were collected")}
+	bargs := BatchArgs{Interval: time.Hour, MaxSize: 100, MaxBytes: 1024 * 1024, Timeout: time.Second,
+		RetryWait: time.Hour, MaxRetryWait: time.Hour}
+	args := blip.SinkFactoryArgs{SinkName: "test-batch-item-errors", MonitorId: "m1"}
+	s, err := batchSinkFor(args, bargs, func() (BatchSender, error) { return mockSender, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	b := s.b
+
+	// Rest of batch was sent, so invalid items are not sent again and the
+	// batcher does not back off
+	s.Send(context.Background(), testMetrics())
+	b.flush()
+	b.Lock()
+	defer b.Unlock()
+	if len(b.items) != 0 {
+		t.Errorf("%d items after item errors, expected 0", len(b.items))
+	}
+	if b.failures != 0 {
+		t.Errorf("%d failures after item errors, expected 0", b.failures)
+	}
+}
+
+func TestParseBatchArgs(t *testing.T) {
+	_, ok, err := parseBatchArgs(map[string]string{"url": "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("batching enabled without batch-interval")
+	}
+
+	got, ok, err := parseBatchArgs(map[string]string{
+		"batch-interval":  "10s",
+		"batch-max-bytes": "1M",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("batching not enabled with batch-interval")
+	}
+	expect := BatchArgs{
+		Interval:     10 * time.Second,
+		MaxSize:      DEFAULT_BATCH_MAX_SIZE,
+		MaxBytes:     1024 * 1024,
+		Timeout:      5 * time.Second,
+		RetryWait:    200 * time.Millisecond,
+		MaxRetryWait: 30 * time.Second,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, _, err := parseBatchArgs(map[string]string{"batch-interval": "0s"}); err == nil {
+		t.Error("no error for batch-interval=0s, expected an error")
+	}
+}
@@ -58,21 +58,28 @@ func NewChronosphere(monitorId string, opts, tags map[string]string) (*Chronosph
 		}
 	}
 
-	if len(tags) > 0 {
-		s.labels = make([]*om.Label, len(tags))
-		i := 0
-		for k, v := range tags {
-			s.labels[i] = &om.Label{
-				Name:  k,
-				Value: v,
-			}
-			i++
-		}
-	}
+	s.labels = omLabels(tags)
 
 	return s, nil
 }
 
+// omLabels returns tags as OpenMetrics labels, or nil if there are no tags.
+func omLabels(tags map[string]string) []*om.Label {
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := make([]*om.Label, len(tags))
+	i := 0
+	for k, v := range tags {
+		labels[i] = &om.Label{
+			Name:  k,
+			Value: v,
+		}
+		i++
+	}
+	return labels
+}
+
 var nameRe = regexp.MustCompile("([^a-zA-Z0-9_])")
 
 // omName converts a Blip domain and metric name to OpenMetrics convention.
@@ -93,12 +100,41 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 		}
 	}()
 
+	fam, err := s.families(m, s.labels) // pre-created in NewChronosphere
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+	n = len(fam)
+
+	lerr = s.send(&om.MetricSet{MetricFamilies: fam})
+	return // implicit lerr
+}
+
+// SendBatch sends metrics from many monitors in one request. It implements
+// BatchSender.
+func (s *Chronosphere) SendBatch(ctx context.Context, items []BatchItem) error {
+	fam := []*om.MetricFamily{}
+	for _, item := range items {
+		f, err := s.families(item.Metrics, omLabels(item.Tags))
+		if err != nil {
+			return err
+		}
+		fam = append(fam, f...)
+	}
+	return s.send(&om.MetricSet{MetricFamilies: fam})
+}
+
+// families returns an OpenMetrics MetricFamily for each Blip metric with the
+// given labels.
+func (s *Chronosphere) families(m *blip.Metrics, labels []*om.Label) ([]*om.MetricFamily, error) {
 	ts := timestamppb.New(m.Begin) // Go timestamp to protobuf timestamp
 	// @todo check blip.Metrics.Meta[ts] and use if set
 
 	// Counter number of Blip metric values so we can pre-alloc OpenMetrics
 	// structs--just an easy micro-optimization to avoid unnecessary memory
 	// alloc using Go append(), because OpenMetrics structs are big
+	n := 0
 	for _, metrics := range m.Values {
 		n += len(metrics)
 	}
@@ -111,9 +147,6 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 	// i.e. MetricFamily is one metric (like Threads_running). So we need one
 	// MetricFamily struct for each metric, as counted above.
 	fam := make([]*om.MetricFamily, n)
-	set := &om.MetricSet{
-		MetricFamilies: fam,
-	}
 
 	// Create the MetricFamily for each Blip metric. Blip metrics are grouped by
 	// domain (e.g. var.global), and each domain has several metrics. This two-level
@@ -131,8 +164,7 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 		if tr == nil {
 			err := fmt.Errorf("no translator for %s", domain)
 			if blip.Strict {
-				lerr = err
-				return // implicit lerr
+				return nil, err
 			}
 			blip.Debug(err.Error() + ", ignoring")
 			continue
@@ -148,7 +180,7 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 				Name: omName(prefix + "_" + shortDomain + "_" + m.Name), // METRIC NAME
 				Metrics: []*om.Metric{
 					{
-						Labels: s.labels, // pre-created in NewChronosphere
+						Labels: labels,
 						MetricPoints: []*om.MetricPoint{
 							{
 								Timestamp: ts,
@@ -183,10 +215,15 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 		} // each metric in a Blip domain
 	} //each Blip domain
 
+	return fam[0:n], nil // less than len(fam) if domains were ignored
+}
+
+// send sends the OpenMetrics data to Chronosphere.
+func (s *Chronosphere) send(set *om.MetricSet) error {
 	// If config.sinks.chronosphere.debug=true, then just print via debug, don't send
 	if s.debug {
 		blip.Debug(set.String())
-		return // success
+		return nil // success
 	}
 
 	// ----------------------------------------------------------------------
@@ -196,8 +233,7 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 	// First, marshal the OpenMetrics data
 	data, err := proto.Marshal(set)
 	if err != nil {
-		lerr = err
-		return // implicit lerr
+		return err
 	}
 
 	// Second, compress data with Snappy
@@ -206,22 +242,19 @@ func (s *Chronosphere) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
 	// Last, HTTP POST the compressed data to Chronosphere collector
 	resp, err := http.Post(s.url, "application/octet-stream", buf)
 	if err != nil {
-		lerr = err
-		return // implicit lerr
+		return err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		lerr = fmt.Errorf("error reading response to POST: %s", err)
-		return // implicit lerr
+		return fmt.Errorf("error reading response to POST: %s", err)
 	}
 	if resp.StatusCode >= 300 {
-		lerr = fmt.Errorf("chronocollector HTTP response code %d, expected 2xx: %s",
+		return fmt.Errorf("chronocollector HTTP response code %d, expected 2xx: %s",
 			resp.StatusCode, string(body))
-		return // implicit lerr
 	}
 
-	return // success
+	return nil // success
 }
 
 func (s *Chronosphere) Name() string {
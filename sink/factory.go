@@ -5,6 +5,7 @@ package sink
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -44,7 +45,47 @@ func Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
 	if !ok {
 		return nil, fmt.Errorf("sink %s not registered", args.SinkName)
 	}
-	return f.Make(args)
+
+	// Filter options and static labels apply to every sink, so handle them
+	// here and remove them from the options passed to the sink factory
+	filter, err := NewFilter(args.Options)
+	if err != nil {
+		return nil, fmt.Errorf("%s sink: %s", args.SinkName, err)
+	}
+	if v, ok := args.Options[OPT_LABELS]; ok {
+		labels, err := parseKV(OPT_LABELS, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s sink: %s", args.SinkName, err)
+		}
+		// Static labels are tags for this sink only
+		tags := make(map[string]string, len(args.Tags)+len(labels))
+		for k, v := range args.Tags {
+			tags[k] = v
+		}
+		for k, v := range labels {
+			tags[k] = v
+		}
+		args.Tags = tags
+	}
+	if len(args.Options) > 0 {
+		opts := make(map[string]string, len(args.Options))
+		for k, v := range args.Options {
+			opts[k] = v
+		}
+		for _, k := range filterOptions {
+			delete(opts, k)
+		}
+		args.Options = opts
+	}
+
+	sink, err := f.Make(args)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		return filtered{sink: sink, filter: filter}, nil
+	}
+	return sink, nil
 }
 
 // --------------------------------------------------------------------------
@@ -70,6 +111,13 @@ var noop = noopSink{}
 func init() {
 	Register("chronosphere", f)
 	Register("signalfx", f)
+	Register("prometheus", f)
+	Register("otlp", f)
+	Register("statsd", f)
+	Register("influxdb", f)
+	Register("graphite", f)
+	Register("file", f)
+	Register("webhook", f)
 	Register("log", f)
 	Register("noop", f)
 }
@@ -103,11 +151,32 @@ func (f *factory) Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
 		return noop, nil
 	}
 
+	// ----------------------------------------------------------------------
+	// Built-in sinks that support batching send metrics from all monitors in
+	// one request per batch-interval. Batching replaces Retry: a failed batch
+	// is sent again with the next batch after a backoff wait, like Retry.
+	bargs, batch, err := parseBatchArgs(args.Options)
+	if err != nil {
+		return nil, fmt.Errorf("%s sink: %s", args.SinkName, err)
+	}
+	if batch {
+		switch args.SinkName {
+		case "chronosphere", "signalfx", "prometheus", "webhook":
+		default:
+			return nil, fmt.Errorf("%s sink does not support batching (option batch-interval)", args.SinkName)
+		}
+		s, err := batchSinkFor(args, bargs, func() (BatchSender, error) { return f.makeBatchSender(args) })
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
 	// ----------------------------------------------------------------------
 	// Built-in sinks use Retry to serialize access and retry on Send
 	// error. First build the specific sink, then return it wrapped in Retry.
 
-	// Parse config.metrics.sink.*.send-timeout, which  is a Retry option
+	// Parse config.metrics.sink.*.send-timeout, etc., which are Retry options
 	retryArgs := RetryArgs{
 		MonitorId: args.MonitorId,
 	}
@@ -135,9 +204,49 @@ func (f *factory) Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
 		}
 		retryArgs.SendRetryWait = d
 	}
+	if v, ok := args.Options["send-retry-max-wait"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.SendRetryMaxWait = d
+	}
+	if v, ok := args.Options["breaker-failures"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid retry breaker-failures: %d: must be zero (disabled) or greater", n)
+		}
+		retryArgs.BreakerFailures = n
+	}
+	if v, ok := args.Options["breaker-cooldown"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.BreakerCooldown = d
+	}
+
+	// Parse config.metrics.sink.*.spool-*, which are also Retry options.
+	// The spool is enabled only if spool-dir is set.
+	if dir, ok := args.Options["spool-dir"]; ok && dir != "" {
+		maxBytes, err := ParseBytes(blip.SetOrDefault(args.Options["spool-max-bytes"], DEFAULT_SPOOL_MAX_BYTES))
+		if err != nil {
+			return nil, fmt.Errorf("invalid spool-max-bytes: %s", err)
+		}
+		maxAge, err := time.ParseDuration(blip.SetOrDefault(args.Options["spool-max-age"], DEFAULT_SPOOL_MAX_AGE))
+		if err != nil {
+			return nil, fmt.Errorf("invalid spool-max-age: %s", err)
+		}
+		retryArgs.Spool, err = NewSpool(filepath.Join(dir, args.MonitorId, args.SinkName), maxBytes, maxAge)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Make specific built-in sink
-	var err error
 	switch args.SinkName {
 	case "chronosphere":
 		retryArgs.Sink, err = NewChronosphere(args.MonitorId, args.Options, args.Tags)
@@ -150,6 +259,48 @@ func (f *factory) Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
 		if err != nil {
 			return nil, err
 		}
+	case "prometheus":
+		httpClient, err := f.HTTPClient.MakeForSink("prometheus", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.Sink, err = NewPrometheus(args.MonitorId, args.Options, args.Tags, httpClient)
+		if err != nil {
+			return nil, err
+		}
+	case "otlp":
+		httpClient, err := f.HTTPClient.MakeForSink("otlp", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.Sink, err = NewOTLP(args.MonitorId, args.Options, args.Tags, httpClient)
+		if err != nil {
+			return nil, err
+		}
+	case "file":
+		retryArgs.Sink, err = NewFile(args.MonitorId, args.Options, args.Tags)
+	case "graphite":
+		retryArgs.Sink, err = NewGraphite(args.MonitorId, args.Options, args.Tags)
+	case "influxdb":
+		httpClient, err := f.HTTPClient.MakeForSink("influxdb", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.Sink, err = NewInfluxDB(args.MonitorId, args.Options, args.Tags, httpClient)
+		if err != nil {
+			return nil, err
+		}
+	case "statsd":
+		retryArgs.Sink, err = NewStatsD(args.MonitorId, args.Options, args.Tags)
+	case "webhook":
+		httpClient, err := f.HTTPClient.MakeForSink("webhook", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		retryArgs.Sink, err = NewWebhook(args.MonitorId, args.Options, args.Tags, httpClient)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("sink %s not registered", args.SinkName)
 	}
@@ -160,3 +311,31 @@ func (f *factory) Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
 	// Return built-in sink as Retry, which implements blip.Sink
 	return NewRetry(retryArgs), nil
 }
+
+// makeBatchSender makes a built-in sink that implements BatchSender. It's called
+// once per batcher with the args of the first monitor to use the batcher.
+func (f *factory) makeBatchSender(args blip.SinkFactoryArgs) (BatchSender, error) {
+	switch args.SinkName {
+	case "chronosphere":
+		return NewChronosphere(args.MonitorId, args.Options, args.Tags)
+	case "signalfx":
+		httpClient, err := f.HTTPClient.MakeForSink("signalfx", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		return NewSignalFx(args.MonitorId, args.Options, args.Tags, httpClient)
+	case "prometheus":
+		httpClient, err := f.HTTPClient.MakeForSink("prometheus", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrometheus(args.MonitorId, args.Options, args.Tags, httpClient)
+	case "webhook":
+		httpClient, err := f.HTTPClient.MakeForSink("webhook", args.MonitorId, args.Options, args.Tags)
+		if err != nil {
+			return nil, err
+		}
+		return NewWebhook(args.MonitorId, args.Options, args.Tags, httpClient)
+	}
+	return nil, fmt.Errorf("%s sink does not support batching", args.SinkName)
+}
@@ -0,0 +1,344 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	DEFAULT_FILE_MAX_SIZE = "100M"
+	DEFAULT_FILE_KEEP     = 10
+
+	fileRotateTimeFormat = "20060102-150405.000"
+)
+
+// File writes metrics to a file as JSON Lines: one JSON object (FileMetrics)
+// per line per Send. The file is rotated by size and, optionally, time.
+type File struct {
+	monitorId string
+	// --
+	path        string
+	maxSize     int64
+	rotateEvery time.Duration
+	gzip        bool
+	keep        int
+	// --
+	*sync.Mutex // guards file: Send and Stop
+	file        *os.File
+	size        int64
+	openedAt    time.Time
+	rotated     *regexp.Regexp // path.TIMESTAMP[.gz]
+}
+
+var _ blip.SinkStopper = &File{}
+
+// FileMetrics is the JSON object written for each blip.Metrics.
+type FileMetrics struct {
+	MonitorId string
+	Plan      string
+	Level     string
+	State     string
+	Begin     time.Time
+	End       time.Time
+	Values    map[string][]FileMetricValue // keyed on domain
+}
+
+// FileMetricValue is a blip.MetricValue with a string type.
+type FileMetricValue struct {
+	Name  string
+	Value float64
+	Type  string            // counter, gauge, bool, or event
+	Group map[string]string `json:",omitempty"`
+	Meta  map[string]string `json:",omitempty"`
+}
+
+// NewFileMetrics returns metrics as FileMetrics and the number of metric values.
+// It's also used by the webhook sink.
+func NewFileMetrics(m *blip.Metrics) (FileMetrics, int) {
+	fm := FileMetrics{
+		MonitorId: m.MonitorId,
+		Plan:      m.Plan,
+		Level:     m.Level,
+		State:     m.State,
+		Begin:     m.Begin,
+		End:       m.End,
+		Values:    make(map[string][]FileMetricValue, len(m.Values)),
+	}
+	n := 0
+	for domain, metrics := range m.Values {
+		values := make([]FileMetricValue, len(metrics))
+		for i := range metrics {
+			values[i] = FileMetricValue{
+				Name:  metrics[i].Name,
+				Value: metrics[i].Value,
+				Type:  fileMetricType(metrics[i].Type),
+				Group: metrics[i].Group,
+				Meta:  metrics[i].Meta,
+			}
+		}
+		fm.Values[domain] = values
+		n += len(metrics)
+	}
+	return fm, n
+}
+
+func NewFile(monitorId string, opts, tags map[string]string) (*File, error) {
+	s := &File{
+		monitorId: monitorId,
+		// --
+		keep:  DEFAULT_FILE_KEEP,
+		Mutex: &sync.Mutex{},
+	}
+
+	maxSize := DEFAULT_FILE_MAX_SIZE
+	for k, v := range opts {
+		switch k {
+		case "path":
+			s.path = v
+		case "max-size":
+			maxSize = v
+		case "rotate-every":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file sink rotate-every: %s: %s", v, err)
+			}
+			s.rotateEvery = d
+		case "gzip":
+			s.gzip = blip.Bool(v)
+		case "keep":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file sink keep: %s: %s", v, err)
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("invalid file sink keep: %d: must be zero or greater", n)
+			}
+			s.keep = n
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.path == "" {
+		return nil, fmt.Errorf("file sink requires path")
+	}
+	n, err := ParseBytes(maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file sink max-size: %s", err)
+	}
+	s.maxSize = n
+	s.rotated = regexp.MustCompile("^" + regexp.QuoteMeta(s.path) + `\.\d{8}-\d{6}\.\d{3}(\.gz)?$`)
+
+	return s, nil
+}
+
+func (s *File) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	status.Monitor(s.monitorId, "file", "writing metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "file", "last wrote %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "file", "error on last write at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	s.Lock()
+	defer s.Unlock()
+
+	fm, n := NewFileMetrics(m)
+	line, err := json.Marshal(fm)
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+	line = append(line, '\n')
+
+	// Rotate before writing if the line would make the file too big, or the
+	// file is too old. A line larger than max-size is written to an empty file.
+	if s.file != nil {
+		if (s.size > 0 && s.size+int64(len(line)) > s.maxSize) ||
+			(s.rotateEvery > 0 && time.Since(s.openedAt) >= s.rotateEvery) {
+			if lerr = s.rotate(); lerr != nil {
+				return // implicit lerr
+			}
+		}
+	}
+	if s.file == nil {
+		if lerr = s.open(); lerr != nil {
+			return // implicit lerr
+		}
+	}
+
+	nBytes, err := s.file.Write(line)
+	s.size += int64(nBytes)
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+
+	return // success
+}
+
+func (s *File) Name() string {
+	return "file"
+}
+
+// Stop closes the file when the monitor stops or restarts. If the monitor
+// restarts, the next Send reopens the file. It implements blip.SinkStopper.
+func (s *File) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Close(); err != nil {
+		blip.Debug("%s: closing %s: %s", s.monitorId, s.path, err)
+	}
+	s.file = nil
+}
+
+func (s *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = fi.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+// rotate closes and renames the current file to path.TIMESTAMP, gzips it if
+// enabled, then removes the oldest rotated files to keep only the keep newest.
+func (s *File) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	rotated := s.path + "." + time.Now().UTC().Format(fileRotateTimeFormat)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+	if s.gzip {
+		if err := gzipFile(rotated); err != nil {
+			return err
+		}
+	}
+
+	// Rotated file names sort by time because of the timestamp format. Only
+	// rotated files are removed, not other files like path.bak.
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return err
+	}
+	files := []string{}
+	for _, file := range matches {
+		if s.rotated.MatchString(file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	for len(files) > s.keep {
+		blip.Debug("%s: removing %s", s.monitorId, files[0])
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// gzipFile compresses file to file.gz and removes file.
+func gzipFile(file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(file+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+func fileMetricType(t byte) string {
+	switch t {
+	case blip.COUNTER:
+		return "counter"
+	case blip.GAUGE:
+		return "gauge"
+	case blip.BOOL:
+		return "bool"
+	case blip.EVENT:
+		return "event"
+	}
+	return "unknown"
+}
+
+// ParseBytes parses a byte size like "100M": an integer with an optional
+// K, M, or G suffix (powers of 1024).
+func ParseBytes(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1024
+	case strings.HasSuffix(s, "M"):
+		mult = 1024 * 1024
+	case strings.HasSuffix(s, "G"):
+		mult = 1024 * 1024 * 1024
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("%d: must be greater than zero", n)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestFileSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	s, err := NewFile("m1", map[string]string{"path": path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Date(2022, 4, 15, 12, 0, 0, 0, time.UTC)
+	m := &blip.Metrics{
+		Begin:     begin,
+		End:       begin.Add(50 * time.Millisecond),
+		MonitorId: "m1",
+		Plan:      "p1",
+		Level:     "kpi",
+		State:     blip.STATE_ACTIVE,
+		Values: map[string][]blip.MetricValue{
+			"size.database": {
+				{Name: "bytes", Type: blip.GAUGE, Value: 512, Group: map[string]string{"db": "app"}},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		var got FileMetrics
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		expect := FileMetrics{
+			MonitorId: "m1",
+			Plan:      "p1",
+			Level:     "kpi",
+			State:     blip.STATE_ACTIVE,
+			Begin:     begin,
+			End:       begin.Add(50 * time.Millisecond),
+			Values: map[string][]FileMetricValue{
+				"size.database": {
+					{Name: "bytes", Type: "gauge", Value: 512, Group: map[string]string{"db": "app"}},
+				},
+			},
+		}
+		if diff := deep.Equal(got, expect); diff != nil {
+			t.Error(diff)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("got %d lines, expected 2", lines)
+	}
+}
+
+func TestFileRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.jsonl")
+	opts := map[string]string{
+		"path":     path,
+		"max-size": "1", // every Send rotates
+		"gzip":     "yes",
+		"keep":     "2",
+	}
+	s, err := NewFile("m1", opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unrelated files with the same prefix must not be removed
+	unrelated := []string{path + ".bak", path + ".db2"}
+	for _, file := range unrelated {
+		if err := os.WriteFile(file, []byte("x"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &blip.Metrics{Level: "kpi"}
+	for i := 0; i < 5; i++ {
+		if err := s.Send(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond) // unique rotated file names
+	}
+
+	for _, file := range unrelated {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("unrelated file removed: %s", err)
+		}
+	}
+
+	files, err := filepath.Glob(path + ".*.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("got %d rotated files, expected 2: %v", len(files), files)
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".gz") {
+			t.Errorf("rotated file %s not gzipped", file)
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("current file: %s", err)
+	}
+
+	// Stop closes the file, and Send after Stop (monitor restart) reopens it
+	s.Stop()
+	if s.file != nil {
+		t.Error("file not closed by Stop")
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := map[string]int64{
+		"100":  100,
+		"10K":  10 * 1024,
+		"100M": 100 * 1024 * 1024,
+		"1g":   1024 * 1024 * 1024,
+	}
+	for s, expect := range tests {
+		got, err := ParseBytes(s)
+		if err != nil {
+			t.Errorf("%s: error: %s", s, err)
+			continue
+		}
+		if got != expect {
+			t.Errorf("%s: got %d, expected %d", s, got, expect)
+		}
+	}
+	for _, s := range []string{"", "M", "-1", "1T"} {
+		if _, err := ParseBytes(s); err == nil {
+			t.Errorf("%q: no error, expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cashapp/blip"
+)
+
+// Filter options, which are sink options applied by sink.Make to any sink,
+// built-in or custom. They are removed from the options passed to the sink.
+const (
+	OPT_INCLUDE_DOMAINS = "include-domains"
+	OPT_EXCLUDE_DOMAINS = "exclude-domains"
+	OPT_INCLUDE_METRICS = "include-metrics"
+	OPT_EXCLUDE_METRICS = "exclude-metrics"
+	OPT_RENAME_METRICS  = "rename-metrics"
+	OPT_DROP_GROUPS     = "drop-groups"
+	OPT_RENAME_GROUPS   = "rename-groups"
+	OPT_LABELS          = "labels"
+)
+
+var filterOptions = []string{
+	OPT_INCLUDE_DOMAINS,
+	OPT_EXCLUDE_DOMAINS,
+	OPT_INCLUDE_METRICS,
+	OPT_EXCLUDE_METRICS,
+	OPT_RENAME_METRICS,
+	OPT_DROP_GROUPS,
+	OPT_RENAME_GROUPS,
+	OPT_LABELS,
+}
+
+// Filter includes, excludes, and renames metrics before they're sent to a sink.
+// Domains are matched by name ("size.database"), and metrics by domain-qualified
+// name ("size.database.bytes").
+type Filter struct {
+	includeDomains *pattern
+	excludeDomains *pattern
+	includeMetrics *pattern
+	excludeMetrics *pattern
+	renameMetrics  map[string]string // domain.metric => new metric name
+	dropGroups     map[string]bool
+	renameGroups   map[string]string // old key => new key
+}
+
+// NewFilter returns a Filter from the filter options in opts, or nil if there
+// are none. Option labels is not a filter; see Make.
+func NewFilter(opts map[string]string) (*Filter, error) {
+	f := &Filter{}
+	set := false
+	var err error
+	for k, v := range opts {
+		switch k {
+		case OPT_INCLUDE_DOMAINS:
+			f.includeDomains, err = newPattern(k, v)
+		case OPT_EXCLUDE_DOMAINS:
+			f.excludeDomains, err = newPattern(k, v)
+		case OPT_INCLUDE_METRICS:
+			f.includeMetrics, err = newPattern(k, v)
+		case OPT_EXCLUDE_METRICS:
+			f.excludeMetrics, err = newPattern(k, v)
+		case OPT_RENAME_METRICS:
+			f.renameMetrics, err = parseKV(k, v)
+		case OPT_RENAME_GROUPS:
+			f.renameGroups, err = parseKV(k, v)
+		case OPT_DROP_GROUPS:
+			f.dropGroups = map[string]bool{}
+			for _, key := range strings.Split(v, ",") {
+				f.dropGroups[strings.TrimSpace(key)] = true
+			}
+		default:
+			continue // not a filter option
+		}
+		if err != nil {
+			return nil, err
+		}
+		set = true
+	}
+	if !set {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Apply returns a filtered copy of metrics. The metrics are not modified because
+// the same metrics are sent to every sink.
+func (f *Filter) Apply(m *blip.Metrics) *blip.Metrics {
+	fm := *m // copy
+	fm.Values = make(map[string][]blip.MetricValue, len(m.Values))
+
+	for domain, metrics := range m.Values {
+		if f.includeDomains != nil && !f.includeDomains.match(domain) {
+			continue
+		}
+		if f.excludeDomains != nil && f.excludeDomains.match(domain) {
+			continue
+		}
+
+		values := make([]blip.MetricValue, 0, len(metrics))
+		for _, v := range metrics { // v is a copy
+			name := domain + "." + v.Name
+			if f.includeMetrics != nil && !f.includeMetrics.match(name) {
+				continue
+			}
+			if f.excludeMetrics != nil && f.excludeMetrics.match(name) {
+				continue
+			}
+			if newName, ok := f.renameMetrics[name]; ok {
+				v.Name = newName
+			}
+			if len(v.Group) > 0 && (f.dropGroups != nil || f.renameGroups != nil) {
+				group := make(map[string]string, len(v.Group))
+				for k, gv := range v.Group {
+					if f.dropGroups[k] {
+						continue
+					}
+					if newKey, ok := f.renameGroups[k]; ok {
+						k = newKey
+					}
+					group[k] = gv
+				}
+				v.Group = group
+			}
+			values = append(values, v)
+		}
+		if len(values) > 0 {
+			fm.Values[domain] = values
+		}
+	}
+
+	return &fm
+}
+
+// --------------------------------------------------------------------------
+
+// filtered is a pseudo-sink that applies a Filter before calling the real sink.
+type filtered struct {
+	sink   blip.Sink
+	filter *Filter
+}
+
+var _ blip.SinkStopper = filtered{}
+var _ blip.SinkErrorReporter = filtered{}
+
+// Send sends the filtered metrics. If the filter removes all metrics, which is
+// common when other levels collect other domains, the real sink is not called
+// because most sinks return an error for no metrics, which Retry would retry.
+func (s filtered) Send(ctx context.Context, m *blip.Metrics) error {
+	fm := s.filter.Apply(m)
+	if len(fm.Values) == 0 {
+		return nil
+	}
+	return s.sink.Send(ctx, fm)
+}
+
+func (s filtered) Name() string {
+	return s.sink.Name()
+}
+
+func (s filtered) Stop() {
+	if stopper, ok := s.sink.(blip.SinkStopper); ok {
+		stopper.Stop()
+	}
+}
+
+// BlipMetrics returns the real sink metrics for the blip domain, if any.
+// It implements blipmetrics.Source.
+func (s filtered) BlipMetrics() []blip.MetricValue {
+	if src, ok := s.sink.(interface{ BlipMetrics() []blip.MetricValue }); ok {
+		return src.BlipMetrics()
+	}
+	return nil
+}
+
+func (s filtered) SinkError() string {
+	if r, ok := s.sink.(blip.SinkErrorReporter); ok {
+		return r.SinkError()
+	}
+	return ""
+}
+
+// --------------------------------------------------------------------------
+
+// pattern matches a comma-separated list of globs, like "status.*,var.global",
+// or one regular expression between slashes, like "/^size\.(database|table)$/".
+type pattern struct {
+	globs []string
+	re    *regexp.Regexp
+}
+
+func newPattern(opt, v string) (*pattern, error) {
+	v = strings.TrimSpace(v)
+	if len(v) > 2 && strings.HasPrefix(v, "/") && strings.HasSuffix(v, "/") {
+		re, err := regexp.Compile(v[1 : len(v)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regex: %s: %s", opt, v, err)
+		}
+		return &pattern{re: re}, nil
+	}
+	p := &pattern{}
+	for _, glob := range strings.Split(v, ",") {
+		glob = strings.TrimSpace(glob)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s glob: %s: %s", opt, glob, err)
+		}
+		p.globs = append(p.globs, glob)
+	}
+	return p, nil
+}
+
+func (p *pattern) match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	for _, glob := range p.globs {
+		if ok, _ := path.Match(glob, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseKV parses a comma-separated list of key:value pairs.
+func parseKV(opt, v string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, kv := range strings.Split(v, ",") {
+		f := strings.SplitN(kv, ":", 2)
+		if len(f) != 2 || strings.TrimSpace(f[0]) == "" || strings.TrimSpace(f[1]) == "" {
+			return nil, fmt.Errorf("invalid %s: %s: expected key:value", opt, kv)
+		}
+		m[strings.TrimSpace(f[0])] = strings.TrimSpace(f[1])
+	}
+	return m, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/test/mock"
+)
+
+func testMetrics() *blip.Metrics {
+	return &blip.Metrics{
+		Level: "kpi",
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+				{Name: "threads_connected", Type: blip.GAUGE, Value: 20},
+				{Name: "queries", Type: blip.COUNTER, Value: 1000},
+			},
+			"var.global": {
+				{Name: "max_connections", Type: blip.GAUGE, Value: 100},
+			},
+			"size.table": {
+				{Name: "rows", Type: blip.GAUGE, Value: 5, Group: map[string]string{"db": "app", "tbl": "t1"}},
+			},
+		},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	opts := map[string]string{
+		OPT_EXCLUDE_DOMAINS: "var.*",
+		OPT_INCLUDE_METRICS: `/^(status\.global\.threads_|size\.)/`,
+		OPT_EXCLUDE_METRICS: "status.global.threads_connected",
+		OPT_RENAME_METRICS:  "status.global.threads_running:running",
+		OPT_DROP_GROUPS:     "db",
+		OPT_RENAME_GROUPS:   "tbl:table",
+	}
+	f, err := NewFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := testMetrics()
+	got := f.Apply(m)
+	expect := map[string][]blip.MetricValue{
+		"status.global": {
+			{Name: "running", Type: blip.GAUGE, Value: 7},
+		},
+		"size.table": {
+			{Name: "rows", Type: blip.GAUGE, Value: 5, Group: map[string]string{"table": "t1"}},
+		},
+	}
+	if diff := deep.Equal(got.Values, expect); diff != nil {
+		t.Error(diff)
+	}
+	if got.Level != "kpi" {
+		t.Errorf("Level %s, expected kpi", got.Level)
+	}
+
+	// Original metrics must not be modified because other sinks get them too
+	if diff := deep.Equal(m, testMetrics()); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFilteredSendEmpty(t *testing.T) {
+	// Like include-domains=size.database when another level collects only
+	// status.global: all metrics are filtered out, so the real sink must not
+	// be called with empty metrics
+	f, err := NewFilter(map[string]string{OPT_INCLUDE_DOMAINS: "size.database"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := 0
+	s := filtered{
+		filter: f,
+		sink: mock.Sink{
+			SendFunc: func(ctx context.Context, m *blip.Metrics) error {
+				sent++
+				return nil
+			},
+		},
+	}
+
+	if err := s.Send(context.Background(), testMetrics()); err != nil {
+		t.Error(err)
+	}
+	if sent != 0 {
+		t.Errorf("real sink called %d times for filtered-out level, expected 0", sent)
+	}
+
+	m := testMetrics()
+	m.Values["size.database"] = []blip.MetricValue{{Name: "bytes", Type: blip.GAUGE, Value: 10}}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Error(err)
+	}
+	if sent != 1 {
+		t.Errorf("real sink called %d times, expected 1", sent)
+	}
+}
+
+func TestFilterOptions(t *testing.T) {
+	f, err := NewFilter(map[string]string{"url": "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != nil {
+		t.Error("got Filter, expected nil without filter options")
+	}
+
+	invalid := []map[string]string{
+		{OPT_INCLUDE_DOMAINS: "/(/"},
+		{OPT_RENAME_METRICS: "status.global.queries"},
+	}
+	for _, opts := range invalid {
+		if _, err := NewFilter(opts); err == nil {
+			t.Errorf("%v: no error, expected an error", opts)
+		}
+	}
+}
+
+func TestMakeFilter(t *testing.T) {
+	var gotTags map[string]string
+	var gotOpts map[string]string
+	Register("test-filter", factoryFunc(func(args blip.SinkFactoryArgs) (blip.Sink, error) {
+		gotTags = args.Tags
+		gotOpts = args.Options
+		return noop, nil
+	}))
+
+	s, err := Make(blip.SinkFactoryArgs{
+		SinkName:  "test-filter",
+		MonitorId: "m1",
+		Options: map[string]string{
+			"url":               "http://localhost",
+			OPT_INCLUDE_DOMAINS: "status.global",
+			OPT_LABELS:          "team:dba",
+		},
+		Tags: map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(filtered); !ok {
+		t.Errorf("got sink %T, expected filtered", s)
+	}
+	if diff := deep.Equal(gotOpts, map[string]string{"url": "http://localhost"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(gotTags, map[string]string{"env": "prod", "team": "dba"}); diff != nil {
+		t.Error(diff)
+	}
+	if err := s.Send(context.Background(), testMetrics()); err != nil {
+		t.Error(err)
+	}
+}
+
+type factoryFunc func(blip.SinkFactoryArgs) (blip.Sink, error)
+
+func (f factoryFunc) Make(args blip.SinkFactoryArgs) (blip.Sink, error) {
+	return f(args)
+}
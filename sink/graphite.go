@@ -0,0 +1,330 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sink/tr"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	GRAPHITE_PROTOCOL_PLAINTEXT = "plaintext"
+	GRAPHITE_PROTOCOL_PICKLE    = "pickle"
+
+	DEFAULT_GRAPHITE_PLAINTEXT_ADDR = "127.0.0.1:2003"
+	DEFAULT_GRAPHITE_PICKLE_ADDR    = "127.0.0.1:2004"
+	DEFAULT_GRAPHITE_TEMPLATE       = "mysql.{monitor.id}.{domain}.{metric}"
+
+	// Max metrics per pickle message; Carbon rejects messages larger than 1 MB by default
+	graphitePickleBatch = 1000
+)
+
+// Graphite sends metrics to Graphite (Carbon) over TCP using the plaintext
+// or pickle protocol.
+type Graphite struct {
+	monitorId string
+	tags      map[string]string   // monitor.tags for {tag.NAME}
+	tr        tr.DomainTranslator // graphite.metric-translator
+	// --
+	protocol    string
+	addr        string
+	template    string
+	*sync.Mutex // guards conn: Send and Stop
+	conn        net.Conn
+}
+
+func NewGraphite(monitorId string, opts, tags map[string]string) (*Graphite, error) {
+	s := &Graphite{
+		monitorId: monitorId,
+		tags:      tags,
+		// --
+		protocol: GRAPHITE_PROTOCOL_PLAINTEXT,
+		template: DEFAULT_GRAPHITE_TEMPLATE,
+		Mutex:    &sync.Mutex{},
+	}
+
+	for k, v := range opts {
+		switch k {
+		case "addr":
+			s.addr = v
+		case "protocol":
+			switch v {
+			case GRAPHITE_PROTOCOL_PLAINTEXT, GRAPHITE_PROTOCOL_PICKLE:
+				s.protocol = v
+			default:
+				return nil, fmt.Errorf("invalid graphite sink protocol: %s: valid values: %s, %s", v, GRAPHITE_PROTOCOL_PLAINTEXT, GRAPHITE_PROTOCOL_PICKLE)
+			}
+		case "template":
+			s.template = v
+		case "metric-translator":
+			tr, err := tr.Make(v)
+			if err != nil {
+				return nil, err
+			}
+			s.tr = tr
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.addr == "" {
+		if s.protocol == GRAPHITE_PROTOCOL_PICKLE {
+			s.addr = DEFAULT_GRAPHITE_PICKLE_ADDR
+		} else {
+			s.addr = DEFAULT_GRAPHITE_PLAINTEXT_ADDR
+		}
+	}
+
+	if err := ValidateGraphiteTemplate(s.template, tags); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+var _ blip.SinkStopper = &Graphite{}
+
+func (s *Graphite) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	s.Lock()
+	defer s.Unlock()
+	status.Monitor(s.monitorId, "graphite", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "graphite", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "graphite", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	points := s.points(m)
+	n = len(points)
+	if n == 0 {
+		return // success (nothing to send)
+	}
+
+	// Connect on first send, or reconnect after an error
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			lerr = err
+			return // implicit lerr
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	var buf bytes.Buffer
+	if s.protocol == GRAPHITE_PROTOCOL_PICKLE {
+		for i := 0; i < len(points); i += graphitePickleBatch {
+			j := i + graphitePickleBatch
+			if j > len(points) {
+				j = len(points)
+			}
+			pickle := graphitePickle(points[i:j])
+			binary.Write(&buf, binary.BigEndian, uint32(len(pickle)))
+			buf.Write(pickle)
+		}
+	} else {
+		for _, p := range points {
+			// path value timestamp\n
+			fmt.Fprintf(&buf, "%s %s %d\n", p.path, strconv.FormatFloat(p.value, 'f', -1, 64), p.ts)
+		}
+	}
+
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.conn.Close()
+		s.conn = nil // reconnect on next Send
+		lerr = err
+		return // implicit lerr
+	}
+
+	return // success
+}
+
+func (s *Graphite) Name() string {
+	return "graphite"
+}
+
+// Stop closes the connection. It implements blip.SinkStopper.
+func (s *Graphite) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+type graphitePoint struct {
+	path  string
+	value float64
+	ts    int64 // Unix seconds
+}
+
+func (s *Graphite) points(m *blip.Metrics) []graphitePoint {
+	points := []graphitePoint{}
+	for domain, metrics := range m.Values {
+		for i := range metrics {
+			ts := m.Begin.Unix()
+			if tsStr, ok := metrics[i].Meta["ts"]; ok { // per-metric timestamp (e.g. aws.rds)
+				tsMs, err := strconv.ParseInt(tsStr, 10, 64)
+				if err != nil {
+					blip.Debug("invalid timestamp for %s %s: %s: %s", domain, metrics[i].Name, tsStr, err)
+					continue
+				}
+				ts = tsMs / 1000
+			}
+			var path string
+			if s.tr == nil {
+				path = GraphitePath(s.template, s.monitorId, domain, metrics[i].Name, s.tags, metrics[i].Group)
+			} else {
+				path = graphitePath(s.template, s.monitorId, domain, s.tr.Translate(domain, metrics[i].Name), true, s.tags, metrics[i].Group)
+			}
+			points = append(points, graphitePoint{
+				path:  path,
+				value: metrics[i].Value,
+				ts:    ts,
+			})
+		}
+	}
+	return points
+}
+
+var graphiteVarRe = regexp.MustCompile(`{([a-z.\-_A-Z0-9]+)}`)
+
+// ValidateGraphiteTemplate returns an error if the template has an unknown
+// variable, or a {tag.NAME} variable for a tag that's not set.
+func ValidateGraphiteTemplate(template string, tags map[string]string) error {
+	for _, v := range graphiteVarRe.FindAllStringSubmatch(template, -1) {
+		switch {
+		case v[1] == "monitor.id", v[1] == "domain", v[1] == "metric", v[1] == "group":
+		case strings.HasPrefix(v[1], "tag."):
+			if _, ok := tags[strings.TrimPrefix(v[1], "tag.")]; !ok {
+				return fmt.Errorf("invalid graphite sink template: %s: tag %s not set", template, strings.TrimPrefix(v[1], "tag."))
+			}
+		default:
+			return fmt.Errorf("invalid graphite sink template: %s: unknown variable {%s}", template, v[1])
+		}
+	}
+	if !strings.Contains(template, "{metric}") {
+		return fmt.Errorf("invalid graphite sink template: %s: {metric} is required", template)
+	}
+	return nil
+}
+
+// GraphitePath returns the Graphite path for a metric by replacing template
+// variables: {monitor.id}, {domain}, {metric}, {group}, and {tag.NAME}.
+// Group keys and values are encoded as "key.value" path nodes (sorted by key)
+// in place of {group} or, if the template does not have {group}, after the
+// metric name. Each node is sanitized; domain dots are kept as path separators.
+func GraphitePath(template, monitorId, domain, metric string, tags, group map[string]string) string {
+	return graphitePath(template, monitorId, domain, metric, false, tags, group)
+}
+
+// graphitePath returns the Graphite path like GraphitePath. If metricPath is
+// true, the metric is a path (a translated name, like "mysql.status.queries"):
+// each dot-separated node is sanitized, like domain.
+func graphitePath(template, monitorId, domain, metric string, metricPath bool, tags, group map[string]string) string {
+	var groupPath string
+	if len(group) > 0 {
+		keys := make([]string, 0, len(group))
+		for k := range group {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		nodes := make([]string, 0, len(keys)*2)
+		for _, k := range keys {
+			nodes = append(nodes, graphiteNode(k), graphiteNode(group[k]))
+		}
+		groupPath = strings.Join(nodes, ".")
+	}
+
+	domainNodes := strings.Split(domain, ".")
+	for i := range domainNodes {
+		domainNodes[i] = graphiteNode(domainNodes[i])
+	}
+	metricNode := graphiteNode(metric)
+	if metricPath {
+		metricNodes := strings.Split(metric, ".")
+		for i := range metricNodes {
+			metricNodes[i] = graphiteNode(metricNodes[i])
+		}
+		metricNode = strings.Join(metricNodes, ".")
+	}
+
+	path := graphiteVarRe.ReplaceAllStringFunc(template, func(v string) string {
+		switch v = v[1 : len(v)-1]; v {
+		case "monitor.id":
+			return graphiteNode(monitorId)
+		case "domain":
+			return strings.Join(domainNodes, ".")
+		case "metric":
+			return metricNode
+		case "group":
+			return groupPath
+		default: // tag.NAME
+			return graphiteNode(tags[strings.TrimPrefix(v, "tag.")])
+		}
+	})
+	if groupPath != "" && !strings.Contains(template, "{group}") {
+		path += "." + groupPath
+	}
+
+	// Remove empty nodes, e.g. from {group} without a group
+	for strings.Contains(path, "..") {
+		path = strings.ReplaceAll(path, "..", ".")
+	}
+	return strings.Trim(path, ".")
+}
+
+var graphiteNodeRe = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+
+// graphiteNode sanitizes one path node: everything except letters, numbers,
+// underscore, and hyphen (including dots) is replaced by an underscore.
+func graphiteNode(s string) string {
+	return graphiteNodeRe.ReplaceAllString(s, "_")
+}
+
+// graphitePickle returns points as a Python pickle (protocol 2) list of tuples:
+// [(path, (timestamp, value)), ...], which is the Carbon pickle format.
+func graphitePickle(points []graphitePoint) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0x80, 2}) // PROTO 2
+	b.WriteByte(']')         // EMPTY_LIST
+	b.WriteByte('(')         // MARK
+	for _, p := range points {
+		b.WriteByte('X') // BINUNICODE: 4-byte little-endian length, UTF-8
+		binary.Write(&b, binary.LittleEndian, uint32(len(p.path)))
+		b.WriteString(p.path)
+		b.WriteByte('J') // BININT: 4-byte little-endian signed int
+		binary.Write(&b, binary.LittleEndian, int32(p.ts))
+		b.WriteByte('G') // BINFLOAT: 8-byte big-endian double
+		binary.Write(&b, binary.BigEndian, math.Float64bits(p.value))
+		b.WriteByte(0x86) // TUPLE2: (timestamp, value)
+		b.WriteByte(0x86) // TUPLE2: (path, (timestamp, value))
+	}
+	b.WriteByte('e') // APPENDS
+	b.WriteByte('.') // STOP
+	return b.Bytes()
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestGraphitePath(t *testing.T) {
+	tags := map[string]string{"env": "prod"}
+	tests := []struct {
+		template string
+		domain   string
+		metric   string
+		group    map[string]string
+		expect   string
+	}{
+		{DEFAULT_GRAPHITE_TEMPLATE, "status.global", "threads_running", nil, "mysql.db1_local.status.global.threads_running"},
+		{DEFAULT_GRAPHITE_TEMPLATE, "size.table", "rows", map[string]string{"tbl": "t1", "db": "app"}, "mysql.db1_local.size.table.rows.db.app.tbl.t1"},
+		{"{tag.env}.{monitor.id}.{domain}.{group}.{metric}", "size.database", "bytes", map[string]string{"db": "my app"}, "prod.db1_local.size.database.db.my_app.bytes"},
+		{"{tag.env}.{domain}.{group}.{metric}", "status.global", "queries", nil, "prod.status.global.queries"},
+	}
+	for _, test := range tests {
+		got := GraphitePath(test.template, "db1.local", test.domain, test.metric, tags, test.group)
+		if got != test.expect {
+			t.Errorf("%s: got %s, expected %s", test.template, got, test.expect)
+		}
+	}
+}
+
+func TestValidateGraphiteTemplate(t *testing.T) {
+	tags := map[string]string{"env": "prod"}
+	if err := ValidateGraphiteTemplate("{tag.env}.{domain}.{metric}", tags); err != nil {
+		t.Errorf("got error %s, expected nil", err)
+	}
+	invalid := []string{
+		"{domain}",                   // no {metric}
+		"{tag.dc}.{domain}.{metric}", // tag not set
+		"{host}.{metric}",            // unknown variable
+	}
+	for _, template := range invalid {
+		if err := ValidateGraphiteTemplate(template, tags); err == nil {
+			t.Errorf("%s: no error, expected an error", template)
+		}
+	}
+}
+
+func TestGraphiteSendPlaintext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		got := []string{}
+		s := bufio.NewScanner(conn)
+		for len(got) < 2 && s.Scan() {
+			got = append(got, s.Text())
+		}
+		lines <- got
+	}()
+
+	s, err := NewGraphite("db1", map[string]string{"addr": ln.Addr().String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &blip.Metrics{
+		Begin: time.Unix(1650000000, 0),
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+				{Name: "queries", Type: blip.COUNTER, Value: 1000.5},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	select {
+	case got = <-lines:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for lines")
+	}
+	sort.Strings(got)
+	expect := []string{
+		"mysql.db1.status.global.queries 1000.5 1650000000",
+		"mysql.db1.status.global.threads_running 7 1650000000",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Stop closes the connection (monitor stopped or unloaded)
+	s.Stop()
+	if s.conn != nil {
+		t.Error("conn not nil after Stop")
+	}
+}
+
+func TestGraphitePickle(t *testing.T) {
+	got := graphitePickle([]graphitePoint{{path: "a.b", value: 1, ts: 2}})
+	expect := []byte{
+		0x80, 2, ']', '(',
+		'X', 3, 0, 0, 0, 'a', '.', 'b',
+		'J', 2, 0, 0, 0,
+		'G', 0x3f, 0xf0, 0, 0, 0, 0, 0, 0,
+		0x86, 0x86,
+		'e', '.',
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestGraphiteMetricTranslator(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tr.yaml")
+	yaml := "domains:\n  \"*\":\n    prefix: \"mysql.{domain}.\"\n"
+	if err := ioutil.WriteFile(file, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string]string{
+		"template":          "{monitor.id}.{metric}",
+		"metric-translator": "file:" + file,
+	}
+	s, err := NewGraphite("db1.local", opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Translated name is a path: each node is sanitized, dots are kept
+	got := s.points(&blip.Metrics{
+		Begin: time.Unix(1650000000, 0),
+		Values: map[string][]blip.MetricValue{
+			"size.database": {{Name: "bytes", Type: blip.GAUGE, Value: 512, Group: map[string]string{"db": "app"}}},
+		},
+	})
+	expect := []graphitePoint{{path: "db1_local.mysql.size.database.bytes.db.app", value: 512, ts: 1650000000}}
+	deep.CompareUnexportedFields = true
+	defer func() { deep.CompareUnexportedFields = false }()
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseHeaders parses a headers option: a comma-separated list of name:value
+// HTTP headers. It's used by the sinks that send metrics via HTTP.
+func parseHeaders(sinkName, v string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, kv := range strings.Split(v, ",") {
+		f := strings.SplitN(kv, ":", 2)
+		if len(f) != 2 || strings.TrimSpace(f[0]) == "" {
+			return nil, fmt.Errorf("invalid %s sink headers: %s: expected name:value", sinkName, kv)
+		}
+		headers[strings.TrimSpace(f[0])] = strings.TrimSpace(f[1])
+	}
+	return headers, nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sink/tr"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	DEFAULT_INFLUXDB_URL       = "http://127.0.0.1:8086"
+	DEFAULT_INFLUXDB_PRECISION = "s"
+)
+
+// Time unit for each InfluxDB write precision
+var influxPrecision = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// InfluxDB sends metrics to InfluxDB v2 using line protocol. Each domain is
+// a measurement, and each metric is a field.
+type InfluxDB struct {
+	monitorId string
+	tags      map[string]string   // monitor.tags
+	prefix    string              // influxdb.measurement-prefix
+	tr        tr.DomainTranslator // influxdb.metric-translator
+	// --
+	url       string
+	token     string
+	precision time.Duration
+	gzip      bool
+	client    *http.Client
+}
+
+func NewInfluxDB(monitorId string, opts, tags map[string]string, httpClient *http.Client) (*InfluxDB, error) {
+	s := &InfluxDB{
+		monitorId: monitorId,
+		tags:      tags,
+		// --
+		gzip:   true,
+		client: httpClient, // made by blip.Factory.HTTPClient
+	}
+
+	baseURL := DEFAULT_INFLUXDB_URL
+	precision := DEFAULT_INFLUXDB_PRECISION
+	var org, bucket string
+	for k, v := range opts {
+		switch k {
+		case "url":
+			baseURL = v
+		case "org":
+			org = v
+		case "bucket":
+			bucket = v
+		case "token":
+			s.token = v
+		case "token-file":
+			bytes, err := ioutil.ReadFile(v)
+			if err != nil {
+				return nil, err
+			}
+			s.token = strings.TrimSpace(string(bytes))
+		case "precision":
+			precision = v
+		case "gzip":
+			s.gzip = blip.Bool(v)
+		case "measurement-prefix":
+			if v == "" {
+				return nil, fmt.Errorf("influxdb sink measurement-prefix is empty string; value required when option is specified")
+			}
+			s.prefix = v
+		case "metric-translator":
+			tr, err := tr.Make(v)
+			if err != nil {
+				return nil, err
+			}
+			s.tr = tr
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if org == "" || bucket == "" {
+		return nil, fmt.Errorf("influxdb sink requires org and bucket")
+	}
+	d, ok := influxPrecision[precision]
+	if !ok {
+		return nil, fmt.Errorf("invalid influxdb sink precision: %s: valid values: ns, us, ms, s", precision)
+	}
+	s.precision = d
+
+	q := url.Values{}
+	q.Set("org", org)
+	q.Set("bucket", bucket)
+	q.Set("precision", precision)
+	s.url = strings.TrimSuffix(baseURL, "/") + "/api/v2/write?" + q.Encode()
+
+	if s.client == nil {
+		s.client = &http.Client{}
+	}
+
+	return s, nil
+}
+
+func (s *InfluxDB) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	status.Monitor(s.monitorId, "influxdb", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "influxdb", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "influxdb", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	var lines []byte
+	lines, n = s.lines(m)
+	if n == 0 {
+		return // success (nothing to send)
+	}
+
+	var body io.Reader = bytes.NewReader(lines)
+	if s.gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(lines); err != nil {
+			lerr = err
+			return // implicit lerr
+		}
+		if err := gz.Close(); err != nil {
+			lerr = err
+			return // implicit lerr
+		}
+		body = &buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, body)
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "blip/"+blip.VERSION)
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		lerr = fmt.Errorf("error reading response to POST: %s", err)
+		return // implicit lerr
+	}
+	if resp.StatusCode >= 300 {
+		lerr = fmt.Errorf("influxdb HTTP response code %d, expected 2xx: %s", resp.StatusCode, string(respBody))
+		return // implicit lerr
+	}
+
+	return // success
+}
+
+func (s *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+// lines returns the metrics in line protocol and the number of fields (metrics).
+// Metrics in the same domain with the same group and timestamp are fields in
+// one line:
+//
+//	status.global,env=prod threads_running=7,queries=1000 1650000000
+//	size.database,env=prod,db=app bytes=512 1650000000
+func (s *InfluxDB) lines(m *blip.Metrics) ([]byte, int) {
+	var buf bytes.Buffer
+	n := 0
+	for domain, metrics := range m.Values {
+		measurement := influxEscape(s.prefix+domain, false)
+
+		// Group fields by tag set and timestamp, in order seen
+		fields := map[string][]string{}
+		keys := []string{}
+		for i := range metrics {
+			ts := m.Begin
+			if tsStr, ok := metrics[i].Meta["ts"]; ok { // per-metric timestamp (e.g. aws.rds)
+				tsMs, err := strconv.ParseInt(tsStr, 10, 64)
+				if err != nil {
+					blip.Debug("invalid timestamp for %s %s: %s: %s", domain, metrics[i].Name, tsStr, err)
+					continue
+				}
+				ts = time.UnixMilli(tsMs)
+			}
+			key := influxTags(s.tags, metrics[i].Group) + " " + strconv.FormatInt(ts.UnixNano()/int64(s.precision), 10)
+			if _, ok := fields[key]; !ok {
+				keys = append(keys, key)
+			}
+			field := influxField(metrics[i])
+			if s.tr != nil {
+				v := metrics[i]
+				v.Name = s.tr.Translate(domain, v.Name)
+				field = influxField(v)
+			}
+			fields[key] = append(fields[key],
+				influxEscape(field, true)+"="+strconv.FormatFloat(metrics[i].Value, 'f', -1, 64))
+			n++
+		}
+
+		for _, key := range keys {
+			sp := strings.LastIndex(key, " ")
+			buf.WriteString(measurement)
+			buf.WriteString(key[:sp]) // tags
+			buf.WriteByte(' ')
+			buf.WriteString(strings.Join(fields[key], ","))
+			buf.WriteString(key[sp:]) // " timestamp"
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), n
+}
+
+// influxField returns the field key: the metric name, plus the percentile meta
+// key if any. Percentile metrics, like response_time from percona.response-time
+// and query.global, have the same name and group but different meta (p95, p99),
+// so without the percentile they would be duplicate fields in one line, and
+// InfluxDB keeps only the last one.
+func influxField(v blip.MetricValue) string {
+	for k := range v.Meta {
+		if percentileMeta.MatchString(k) {
+			return v.Name + "_" + k // response_time_p99
+		}
+	}
+	return v.Name
+}
+
+var percentileMeta = regexp.MustCompile(`^p\d+$`)
+
+// influxTags returns ",k=v,k=v" for monitor tags and metric group, sorted by key
+// as recommended by InfluxDB. Group keys overwrite tags with the same name.
+func influxTags(tags, group map[string]string) string {
+	all := make(map[string]string, len(tags)+len(group))
+	for k, v := range tags {
+		all[k] = v
+	}
+	for k, v := range group {
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k, v := range all {
+		if v == "" {
+			continue // line protocol doesn't allow empty tag values
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(influxEscape(k, true))
+		b.WriteByte('=')
+		b.WriteString(influxEscape(all[k], true))
+	}
+	return b.String()
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// influxEscape escapes a measurement name (key=false), or a tag key, tag value,
+// or field key (key=true).
+func influxEscape(s string, key bool) string {
+	if key {
+		return influxKeyEscaper.Replace(s)
+	}
+	return influxMeasurementEscaper.Replace(s)
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestInfluxDBSend(t *testing.T) {
+	var gotReq *http.Request
+	var gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ := io.ReadAll(gz)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	opts := map[string]string{
+		"url":       ts.URL,
+		"org":       "dba",
+		"bucket":    "mysql",
+		"token":     "abc",
+		"precision": "ms",
+	}
+	s, err := NewInfluxDB("m1", opts, map[string]string{"env": "prod"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &blip.Metrics{
+		Begin: time.Unix(1650000000, 0),
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+				{Name: "queries", Type: blip.COUNTER, Value: 1000},
+			},
+			"size.database": {
+				{Name: "bytes", Type: blip.GAUGE, Value: 512, Group: map[string]string{"db": "my app"}},
+				{Name: "bytes", Type: blip.GAUGE, Value: 1.5, Group: map[string]string{"db": "b"}},
+			},
+			"percona.response-time": {
+				{Name: "response_time", Type: blip.GAUGE, Value: 0.5, Meta: map[string]string{"p95": "95.000"}},
+				{Name: "response_time", Type: blip.GAUGE, Value: 0.9, Meta: map[string]string{"p99": "99.000"}},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotReq.URL.Path != "/api/v2/write" {
+		t.Errorf("path %s, expected /api/v2/write", gotReq.URL.Path)
+	}
+	if q := gotReq.URL.Query(); q.Get("org") != "dba" || q.Get("bucket") != "mysql" || q.Get("precision") != "ms" {
+		t.Errorf("query %s, expected org=dba, bucket=mysql, precision=ms", gotReq.URL.RawQuery)
+	}
+	if got := gotReq.Header.Get("Authorization"); got != "Token abc" {
+		t.Errorf("Authorization %q, expected Token abc", got)
+	}
+
+	got := strings.Split(strings.TrimSpace(gotBody), "\n")
+	sort.Strings(got)
+	expect := []string{
+		`percona.response-time,env=prod response_time_p95=0.5,response_time_p99=0.9 1650000000000`,
+		`size.database,db=b,env=prod bytes=1.5 1650000000000`,
+		`size.database,db=my\ app,env=prod bytes=512 1650000000000`,
+		`status.global,env=prod threads_running=7,queries=1000 1650000000000`,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestInfluxDBOptions(t *testing.T) {
+	if _, err := NewInfluxDB("m1", map[string]string{"org": "dba"}, nil, nil); err == nil {
+		t.Error("no error without bucket, expected an error")
+	}
+	opts := map[string]string{
+		"org":       "dba",
+		"bucket":    "mysql",
+		"precision": "m",
+	}
+	if _, err := NewInfluxDB("m1", opts, nil, nil); err == nil {
+		t.Error("no error for invalid precision, expected an error")
+	}
+}
+
+func TestInfluxDBMetricTranslator(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tr.yaml")
+	yaml := "domains:\n  status.global:\n    metrics:\n      threads_running: Threads_running\n"
+	if err := ioutil.WriteFile(file, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string]string{
+		"org":               "dba",
+		"bucket":            "mysql",
+		"metric-translator": "file:" + file,
+	}
+	s, err := NewInfluxDB("m1", opts, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Translator names fields; measurement is still the domain
+	got, _ := s.lines(&blip.Metrics{
+		Begin: time.Unix(1650000000, 0),
+		Values: map[string][]blip.MetricValue{
+			"status.global": {{Name: "threads_running", Type: blip.GAUGE, Value: 7}},
+		},
+	})
+	expect := "status.global Threads_running=7 1650000000\n"
+	if string(got) != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
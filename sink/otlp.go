@@ -0,0 +1,404 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sink/tr"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	OTLP_PROTOCOL_HTTP = "http/protobuf"
+	OTLP_PROTOCOL_GRPC = "grpc"
+
+	DEFAULT_OTLP_HTTP_URL = "http://127.0.0.1:4318/v1/metrics"
+	DEFAULT_OTLP_GRPC_URL = "https://127.0.0.1:4317"
+
+	otlpGRPCPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// OTLP sends metrics to an OpenTelemetry Collector (or any OTLP receiver)
+// using OTLP/HTTP with protobuf payloads or OTLP/gRPC.
+type OTLP struct {
+	monitorId string
+	resource  []byte              // monitor.tags as encoded Resource
+	tr        tr.DomainTranslator // otlp.metric-translator
+	prefix    string              // otlp.metric-prefix
+	// --
+	protocol string
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	start    time.Time // start_time_unix_nano of cumulative sums
+}
+
+func NewOTLP(monitorId string, opts, tags map[string]string, httpClient *http.Client) (*OTLP, error) {
+	s := &OTLP{
+		monitorId: monitorId,
+		// --
+		protocol: OTLP_PROTOCOL_HTTP,
+		headers:  map[string]string{},
+		client:   httpClient, // made by blip.Factory.HTTPClient
+		start:    time.Now(),
+	}
+
+	for k, v := range opts {
+		switch k {
+		case "protocol":
+			switch v {
+			case OTLP_PROTOCOL_HTTP, OTLP_PROTOCOL_GRPC:
+				s.protocol = v
+			default:
+				return nil, fmt.Errorf("invalid otlp sink protocol: %s: valid values: %s, %s", v, OTLP_PROTOCOL_HTTP, OTLP_PROTOCOL_GRPC)
+			}
+		case "url":
+			s.url = v
+		case "headers":
+			headers, err := parseHeaders("otlp", v)
+			if err != nil {
+				return nil, err
+			}
+			s.headers = headers
+		case "metric-translator":
+			tr, err := tr.Make(v)
+			if err != nil {
+				return nil, err
+			}
+			s.tr = tr
+		case "metric-prefix":
+			if v == "" {
+				return nil, fmt.Errorf("otlp sink metric-prefix is empty string; value required when option is specified")
+			}
+			s.prefix = v
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.client == nil {
+		s.client = &http.Client{}
+	}
+
+	switch s.protocol {
+	case OTLP_PROTOCOL_HTTP:
+		if s.url == "" {
+			s.url = DEFAULT_OTLP_HTTP_URL
+		}
+	case OTLP_PROTOCOL_GRPC:
+		if s.url == "" {
+			s.url = DEFAULT_OTLP_GRPC_URL
+		}
+		// gRPC requires HTTP/2, which the Go HTTP client only negotiates over
+		// TLS. Plaintext gRPC (h2c) is not supported; use http/protobuf instead.
+		u, err := url.Parse(s.url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid otlp sink url: %s: %s", s.url, err)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("otlp sink protocol grpc requires an https url; use protocol %s for plaintext", OTLP_PROTOCOL_HTTP)
+		}
+		u.Path = otlpGRPCPath
+		s.url = u.String()
+
+		// A custom transport (e.g. with a proxy) does not try HTTP/2 unless forced
+		if t, ok := s.client.Transport.(*http.Transport); ok && !t.ForceAttemptHTTP2 {
+			t = t.Clone()
+			t.ForceAttemptHTTP2 = true
+			client := *s.client
+			client.Transport = t
+			s.client = &client
+		}
+	}
+
+	// Monitor tags are the same for every request, so encode Resource once.
+	// service.name is required by the OTLP spec; default to "blip".
+	attr := map[string]string{"service.name": "blip"}
+	for k, v := range tags {
+		attr[k] = v
+	}
+	s.resource = otlpAttributes(1, attr) // Resource.attributes = 1
+
+	return s, nil
+}
+
+func (s *OTLP) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	status.Monitor(s.monitorId, "otlp", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "otlp", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "otlp", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	var data []byte
+	data, n = s.request(m)
+	if n == 0 {
+		return // success (nothing to send)
+	}
+
+	if s.protocol == OTLP_PROTOCOL_GRPC {
+		lerr = s.sendGRPC(ctx, data)
+	} else {
+		lerr = s.sendHTTP(ctx, data)
+	}
+	return // implicit lerr
+}
+
+func (s *OTLP) Name() string {
+	return "otlp"
+}
+
+// request returns an encoded ExportMetricsServiceRequest and the number of data
+// points it contains. Like the prometheus sink, the protobuf is encoded directly
+// rather than importing the generated OTLP packages (and gRPC) for a few messages:
+//
+//	ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	ResourceMetrics  { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	Resource         { repeated KeyValue attributes = 1; }
+//	ScopeMetrics     { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	Metric           { string name = 1; oneof data { Gauge gauge = 5; Sum sum = 7; } }
+//	Gauge            { repeated NumberDataPoint data_points = 1; }
+//	Sum              { repeated NumberDataPoint data_points = 1;
+//	                   AggregationTemporality aggregation_temporality = 2; bool is_monotonic = 3; }
+//	NumberDataPoint  { fixed64 start_time_unix_nano = 2; fixed64 time_unix_nano = 3; double as_double = 4;
+//	                   repeated KeyValue attributes = 7; }
+//
+// Cumulative sums (counters) have start_time_unix_nano = when the sink was made,
+// which is when the monitor started (or was reloaded), so receivers can detect
+// resets.
+func (s *OTLP) request(m *blip.Metrics) ([]byte, int) {
+	n := 0
+	var metrics []byte
+	for domain, values := range m.Values {
+		// Group values by metric name so each OTLP Metric has one data point
+		// per group (e.g. per db or per channel)
+		byName := map[string][]blip.MetricValue{}
+		names := []string{}
+		for i := range values {
+			if _, ok := byName[values[i].Name]; !ok {
+				names = append(names, values[i].Name)
+			}
+			byName[values[i].Name] = append(byName[values[i].Name], values[i])
+		}
+
+		for _, metricName := range names {
+			var points []byte
+			mtype := byName[metricName][0].Type
+			for _, v := range byName[metricName] {
+				ts := m.Begin
+				if tsStr, ok := v.Meta["ts"]; ok { // per-metric timestamp (e.g. aws.rds)
+					tsMs, err := strconv.ParseInt(tsStr, 10, 64)
+					if err != nil {
+						blip.Debug("invalid timestamp for %s %s: %s: %s", domain, v.Name, tsStr, err)
+						continue
+					}
+					ts = time.UnixMilli(tsMs)
+				}
+				points = protowire.AppendTag(points, 1, protowire.BytesType) // data_points
+				var start time.Time
+				if v.Type == blip.COUNTER {
+					start = s.start
+					if ts.Before(start) {
+						start = ts
+					}
+				}
+				points = protowire.AppendBytes(points, otlpDataPoint(v, start, ts))
+				n++
+			}
+			if len(points) == 0 {
+				continue
+			}
+
+			var data []byte
+			data = append(data, points...)
+			var dataField protowire.Number
+			if mtype == blip.COUNTER {
+				dataField = 7 // sum
+				data = protowire.AppendTag(data, 2, protowire.VarintType)
+				data = protowire.AppendVarint(data, 2) // AGGREGATION_TEMPORALITY_CUMULATIVE
+				data = protowire.AppendTag(data, 3, protowire.VarintType)
+				data = protowire.AppendVarint(data, 1) // is_monotonic
+			} else {
+				dataField = 5 // gauge
+			}
+
+			// Set full metric name: translator (if any) else Blip standard,
+			// then prefix (if any)
+			var name string
+			if s.tr == nil {
+				name = domain + "." + metricName
+			} else {
+				name = s.tr.Translate(domain, metricName)
+			}
+			name = s.prefix + name
+
+			var metric []byte
+			metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+			metric = protowire.AppendString(metric, name)
+			metric = protowire.AppendTag(metric, dataField, protowire.BytesType)
+			metric = protowire.AppendBytes(metric, data)
+
+			metrics = protowire.AppendTag(metrics, 2, protowire.BytesType) // ScopeMetrics.metrics
+			metrics = protowire.AppendBytes(metrics, metric)
+		}
+	}
+
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, "blip")
+	scope = protowire.AppendTag(scope, 2, protowire.BytesType)
+	scope = protowire.AppendString(scope, blip.VERSION)
+
+	var scopeMetrics []byte
+	scopeMetrics = protowire.AppendTag(scopeMetrics, 1, protowire.BytesType)
+	scopeMetrics = protowire.AppendBytes(scopeMetrics, scope)
+	scopeMetrics = append(scopeMetrics, metrics...)
+
+	var rm []byte
+	rm = protowire.AppendTag(rm, 1, protowire.BytesType)
+	rm = protowire.AppendBytes(rm, s.resource)
+	rm = protowire.AppendTag(rm, 2, protowire.BytesType)
+	rm = protowire.AppendBytes(rm, scopeMetrics)
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, rm)
+
+	return req, n
+}
+
+func (s *OTLP) sendHTTP(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "blip/"+blip.VERSION)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response to POST: %s", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp HTTP response code %d, expected 2xx: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *OTLP) sendGRPC(ctx context.Context, data []byte) error {
+	// gRPC message framing: 1 byte compressed flag (0), 4 byte big-endian
+	// message length, then the message
+	msg := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(msg[1:5], uint32(len(data)))
+	copy(msg[5:], data)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "blip/"+blip.VERSION)
+	for k, v := range s.headers {
+		req.Header.Set(strings.ToLower(k), v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil { // must read body to get trailers
+		return fmt.Errorf("error reading gRPC response: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("otlp gRPC HTTP response code %d, expected 200", resp.StatusCode)
+	}
+
+	// Status is in trailers, or in headers for a trailers-only (error) response
+	code := resp.Trailer.Get("Grpc-Status")
+	grpcMsg := resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code = resp.Header.Get("Grpc-Status")
+		grpcMsg = resp.Header.Get("Grpc-Message")
+	}
+	if code != "0" {
+		if msg, err := url.PathUnescape(grpcMsg); err == nil {
+			grpcMsg = msg
+		}
+		return fmt.Errorf("otlp gRPC status %s: %s", code, grpcMsg)
+	}
+	return nil
+}
+
+// otlpDataPoint returns an encoded NumberDataPoint. Metric groups are data point
+// attributes.
+func otlpDataPoint(v blip.MetricValue, start, ts time.Time) []byte {
+	var b []byte
+	if !start.IsZero() {
+		b = protowire.AppendTag(b, 2, protowire.Fixed64Type) // start_time_unix_nano
+		b = protowire.AppendFixed64(b, uint64(start.UnixNano()))
+	}
+	b = protowire.AppendTag(b, 3, protowire.Fixed64Type) // time_unix_nano
+	b = protowire.AppendFixed64(b, uint64(ts.UnixNano()))
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type) // as_double
+	b = protowire.AppendFixed64(b, math.Float64bits(v.Value))
+	return append(b, otlpAttributes(7, v.Group)...)
+}
+
+// otlpAttributes returns attr encoded as repeated KeyValue field num with
+// string values. Keys are sorted so encoding is deterministic.
+func otlpAttributes(num protowire.Number, attr map[string]string) []byte {
+	keys := make([]string, 0, len(attr))
+	for k := range attr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b []byte
+	for _, k := range keys {
+		// AnyValue.string_value = 1
+		var val []byte
+		val = protowire.AppendTag(val, 1, protowire.BytesType)
+		val = protowire.AppendString(val, attr[k])
+
+		var kv []byte // KeyValue
+		kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+		kv = protowire.AppendString(kv, k)
+		kv = protowire.AppendTag(kv, 2, protowire.BytesType)
+		kv = protowire.AppendBytes(kv, val)
+
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, kv)
+	}
+	return b
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cashapp/blip"
+)
+
+// pbFields decodes one protobuf message into its fields. Length-delimited fields
+// are returned as bytes; fixed64 and varint fields as 8-byte and varint values.
+func pbFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := map[protowire.Number][][]byte{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal("invalid tag")
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatal("invalid field value")
+		}
+		v := b[:n]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		fields[num] = append(fields[num], v)
+		b = b[n:]
+	}
+	return fields
+}
+
+func pbAttributes(t *testing.T, kvs [][]byte) map[string]string {
+	attr := map[string]string{}
+	for _, kv := range kvs {
+		f := pbFields(t, kv)
+		val := pbFields(t, f[2][0])
+		attr[string(f[1][0])] = string(val[1][0])
+	}
+	return attr
+}
+
+func TestOTLPSendHTTP(t *testing.T) {
+	var contentType string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	s, err := NewOTLP("m1", map[string]string{"url": ts.URL}, map[string]string{"env": "prod"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Unix(1650000000, 0)
+	s.start = begin.Add(-time.Minute) // sink (monitor) started before first collect
+	m := &blip.Metrics{
+		Begin: begin,
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+			},
+			"size.database": {
+				{Name: "bytes", Type: blip.COUNTER, Value: 100, Group: map[string]string{"db": "a"}},
+				{Name: "bytes", Type: blip.COUNTER, Value: 200, Group: map[string]string{"db": "b"}},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-protobuf" {
+		t.Errorf("Content-Type %q, expected application/x-protobuf", contentType)
+	}
+
+	rm := pbFields(t, pbFields(t, body)[1][0])
+	resource := pbAttributes(t, pbFields(t, rm[1][0])[1])
+	if diff := deep.Equal(resource, map[string]string{"env": "prod", "service.name": "blip"}); diff != nil {
+		t.Error(diff)
+	}
+
+	type point struct {
+		Value float64
+		Start uint64
+		TS    uint64
+		Attr  map[string]string
+	}
+	type metric struct {
+		Sum    bool
+		Points []point
+	}
+	got := map[string]metric{}
+	for _, buf := range pbFields(t, rm[2][0])[2] {
+		f := pbFields(t, buf)
+		var data map[protowire.Number][][]byte
+		m := metric{}
+		if _, ok := f[7]; ok {
+			m.Sum = true
+			data = pbFields(t, f[7][0])
+			if temp, _ := protowire.ConsumeVarint(data[2][0]); temp != 2 {
+				t.Errorf("aggregation_temporality %d, expected 2 (cumulative)", temp)
+			}
+			if mono, _ := protowire.ConsumeVarint(data[3][0]); mono != 1 {
+				t.Errorf("is_monotonic %d, expected 1 (true)", mono)
+			}
+		} else {
+			data = pbFields(t, f[5][0])
+		}
+		for _, dp := range data[1] {
+			p := pbFields(t, dp)
+			var start uint64
+			if _, ok := p[2]; ok {
+				start, _ = protowire.ConsumeFixed64(p[2][0])
+			}
+			ts, _ := protowire.ConsumeFixed64(p[3][0])
+			v, _ := protowire.ConsumeFixed64(p[4][0])
+			m.Points = append(m.Points, point{
+				Value: math.Float64frombits(v),
+				Start: start,
+				TS:    ts,
+				Attr:  pbAttributes(t, p[7]),
+			})
+		}
+		got[string(f[1][0])] = m
+	}
+	nanos := uint64(begin.UnixNano())
+	startNanos := uint64(s.start.UnixNano())
+	expect := map[string]metric{
+		"status.global.threads_running": {
+			Sum:    false,
+			Points: []point{{Value: 7, TS: nanos, Attr: map[string]string{}}},
+		},
+		"size.database.bytes": {
+			Sum: true,
+			Points: []point{
+				{Value: 100, Start: startNanos, TS: nanos, Attr: map[string]string{"db": "a"}},
+				{Value: 200, Start: startNanos, TS: nanos, Attr: map[string]string{"db": "b"}},
+			},
+		},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestOTLPOptions(t *testing.T) {
+	if _, err := NewOTLP("m1", map[string]string{"protocol": "thrift"}, nil, nil); err == nil {
+		t.Error("no error for invalid protocol, expected an error")
+	}
+
+	opts := map[string]string{
+		"protocol": "grpc",
+		"url":      "http://127.0.0.1:4317",
+	}
+	if _, err := NewOTLP("m1", opts, nil, nil); err == nil {
+		t.Error("no error for grpc with http url, expected an error")
+	}
+
+	opts["url"] = "https://otel:4317"
+	s, err := NewOTLP("m1", opts, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.url != "https://otel:4317"+otlpGRPCPath {
+		t.Errorf("url %s, expected https://otel:4317%s", s.url, otlpGRPCPath)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/prom"
+	"github.com/cashapp/blip/sink/tr"
+	"github.com/cashapp/blip/status"
+)
+
+// Prometheus sends metrics to a Prometheus remote write endpoint (Prometheus,
+// Cortex, Mimir, Thanos, VictoriaMetrics, etc.) using the remote write protocol
+// v1: snappy-compressed protobuf WriteRequest.
+type Prometheus struct {
+	monitorId string
+	labels    map[string]string   // monitor.tags
+	tr        tr.DomainTranslator // prometheus.metric-translator
+	prefix    string              // prometheus.metric-prefix
+	// --
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	headers     map[string]string
+	debug       bool
+	client      *http.Client
+}
+
+func NewPrometheus(monitorId string, opts, tags map[string]string, httpClient *http.Client) (*Prometheus, error) {
+	s := &Prometheus{
+		monitorId: monitorId,
+		// --
+		headers: map[string]string{},
+		client:  httpClient, // made by blip.Factory.HTTPClient
+	}
+
+	for k, v := range opts {
+		switch k {
+		case "url":
+			s.url = v
+		case "username":
+			s.username = v
+		case "password":
+			s.password = v
+		case "bearer-token":
+			s.bearerToken = v
+		case "bearer-token-file":
+			bytes, err := ioutil.ReadFile(v)
+			if err != nil {
+				return nil, err
+			}
+			s.bearerToken = strings.TrimSpace(string(bytes))
+		case "headers":
+			headers, err := parseHeaders("prometheus", v)
+			if err != nil {
+				return nil, err
+			}
+			s.headers = headers
+		case "metric-translator":
+			tr, err := tr.Make(v)
+			if err != nil {
+				return nil, err
+			}
+			s.tr = tr
+		case "metric-prefix":
+			if v == "" {
+				return nil, fmt.Errorf("prometheus sink metric-prefix is empty string; value required when option is specified")
+			}
+			s.prefix = v
+		case "debug":
+			s.debug = blip.Bool(v)
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.url == "" {
+		return nil, fmt.Errorf("prometheus sink requires url")
+	}
+	if s.bearerToken != "" && (s.username != "" || s.password != "") {
+		return nil, fmt.Errorf("prometheus sink bearer-token and username/password are mutually exclusive")
+	}
+	if s.client == nil {
+		s.client = &http.Client{}
+	}
+
+	s.labels = promLabels(tags)
+
+	return s, nil
+}
+
+// promLabels returns tags as Prometheus labels: names are sanitized.
+func promLabels(tags map[string]string) map[string]string {
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		labels[omName(k)] = v
+	}
+	return labels
+}
+
+func (s *Prometheus) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	status.Monitor(s.monitorId, "prometheus", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "prometheus", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "prometheus", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	req, n, err := s.writeRequest(nil, m, s.labels)
+	if err != nil {
+		lerr = err
+		return // implicit lerr
+	}
+
+	// If config.sinks.prometheus.debug=true, then just print via debug, don't send
+	if s.debug {
+		blip.Debug("%s: %d time series, %d bytes", s.monitorId, n, len(req))
+		return // success
+	}
+
+	if n == 0 {
+		return // success (nothing to send)
+	}
+
+	lerr = s.post(ctx, req)
+	return // implicit lerr
+}
+
+// SendBatch sends metrics from many monitors in one WriteRequest. Each monitor's
+// tags are its labels. It implements BatchSender.
+func (s *Prometheus) SendBatch(ctx context.Context, items []BatchItem) error {
+	var req []byte
+	total := 0
+	for _, item := range items {
+		var n int
+		var err error
+		req, n, err = s.writeRequest(req, item.Metrics, promLabels(item.Tags))
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+	if s.debug {
+		blip.Debug("batch of %d monitors: %d time series, %d bytes", len(items), total, len(req))
+		return nil
+	}
+	if total == 0 {
+		return nil
+	}
+	return s.post(ctx, req)
+}
+
+// writeRequest appends the metrics to an encoded WriteRequest and returns it and
+// the number of time series appended.
+func (s *Prometheus) writeRequest(req []byte, m *blip.Metrics, tags map[string]string) ([]byte, int, error) {
+	// Remote write timestamps are milliseconds since epoch
+	ts := m.Begin.UnixMilli()
+
+	// Each Blip metric value is one TimeSeries with one Sample. The WriteRequest
+	// protobuf is encoded directly, rather than importing prompb and its gogo
+	// protobuf dependencies, because the message is very simple:
+	//
+	//   WriteRequest { repeated TimeSeries timeseries = 1; }
+	//   TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+	//   Label        { string name = 1; string value = 2; }
+	//   Sample       { double value = 1; int64 timestamp = 2; }
+	n := 0
+	for domain, metricValues := range m.Values {
+		for i := range metricValues {
+			name := s.name(domain, metricValues[i].Name)
+
+			// Labels: metric name, monitor tags, then metric groups. Group keys
+			// overwrite tags with the same name because groups are more specific.
+			labels := make(map[string]string, 1+len(tags)+len(metricValues[i].Group))
+			for k, v := range tags {
+				labels[k] = v
+			}
+			for k, v := range metricValues[i].Group {
+				labels[omName(k)] = v
+			}
+			labels["__name__"] = name
+
+			req = protowire.AppendTag(req, 1, protowire.BytesType)
+			req = protowire.AppendBytes(req, timeSeries(labels, metricValues[i].Value, ts))
+			n++
+		}
+	}
+	return req, n, nil
+}
+
+// post sends the encoded WriteRequest.
+func (s *Prometheus) post(ctx context.Context, req []byte) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(snappy.Encode(nil, req)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "blip/"+blip.VERSION)
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range s.headers {
+		httpReq.Header.Set(k, v)
+	}
+	if s.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	} else if s.username != "" {
+		httpReq.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response to POST: %s", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("remote write HTTP response code %d, expected 2xx: %s",
+			resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *Prometheus) Name() string {
+	return "prometheus"
+}
+
+// name returns the Prometheus metric name for the Blip domain and metric.
+// If a metric translator is set, it's used; else, the Prometheus translator
+// names are used, like the chronosphere sink. Domains without a Prometheus
+// translator are named mysql_<domain>_<metric>, like mysql_stmt_digest_count.
+func (s *Prometheus) name(domain, metric string) string {
+	var name string
+	if s.tr != nil {
+		name = s.tr.Translate(domain, metric)
+	} else if tr := prom.Translator(domain); tr != nil {
+		prefix, _, shortDomain := tr.Names()
+		name = prefix + "_" + shortDomain + "_" + metric
+	} else {
+		name = "mysql_" + domain + "_" + metric
+	}
+	return omName(s.prefix + name)
+}
+
+// timeSeries returns an encoded TimeSeries with one sample. Labels are sorted
+// by name as required by the remote write protocol.
+func timeSeries(labels map[string]string, value float64, ts int64) []byte {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b []byte
+	for _, k := range names {
+		var l []byte
+		l = protowire.AppendTag(l, 1, protowire.BytesType)
+		l = protowire.AppendString(l, k)
+		l = protowire.AppendTag(l, 2, protowire.BytesType)
+		l = protowire.AppendString(l, labels[k])
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, l)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(ts))
+
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, sample)
+	return b
+}
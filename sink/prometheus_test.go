@@ -0,0 +1,161 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cashapp/blip"
+)
+
+type sample struct {
+	labels map[string]string
+	value  float64
+	ts     int64
+}
+
+// decodeWriteRequest decodes the WriteRequest fields that Prometheus.Send encodes.
+func decodeWriteRequest(t *testing.T, b []byte) []sample {
+	t.Helper()
+	var samples []sample
+	for len(b) > 0 {
+		_, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		ts, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			t.Fatal("invalid TimeSeries")
+		}
+		b = b[n:]
+
+		s := sample{labels: map[string]string{}}
+		for len(ts) > 0 {
+			num, _, n := protowire.ConsumeTag(ts)
+			ts = ts[n:]
+			msg, n := protowire.ConsumeBytes(ts)
+			ts = ts[n:]
+			switch num {
+			case 1: // Label
+				_, _, n = protowire.ConsumeTag(msg)
+				name, n2 := protowire.ConsumeString(msg[n:])
+				msg = msg[n+n2:]
+				_, _, n = protowire.ConsumeTag(msg)
+				value, _ := protowire.ConsumeString(msg[n:])
+				s.labels[name] = value
+			case 2: // Sample
+				_, _, n = protowire.ConsumeTag(msg)
+				v, n2 := protowire.ConsumeFixed64(msg[n:])
+				msg = msg[n+n2:]
+				_, _, n = protowire.ConsumeTag(msg)
+				tsMs, _ := protowire.ConsumeVarint(msg[n:])
+				s.value = math.Float64frombits(v)
+				s.ts = int64(tsMs)
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+func TestPrometheusSend(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		body, _ := io.ReadAll(r.Body)
+		gotBody, _ = snappy.Decode(nil, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	opts := map[string]string{
+		"url":          ts.URL,
+		"bearer-token": "abc",
+		"headers":      "X-Scope-OrgID: tenant1",
+	}
+	tags := map[string]string{"env": "prod"}
+	s, err := NewPrometheus("m1", opts, tags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Unix(1650000000, 0)
+	m := &blip.Metrics{
+		Begin: begin,
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+			},
+			"var.global": {
+				{Name: "max_connections", Type: blip.GAUGE, Value: 100, Group: map[string]string{"env": "group"}},
+			},
+			"stmt.digest": { // no Prometheus translator
+				{Name: "count", Type: blip.COUNTER, Value: 5, Group: map[string]string{"digest": "abc"}},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gotHeader.Get("Content-Encoding"); got != "snappy" {
+		t.Errorf("Content-Encoding %q, expected snappy", got)
+	}
+	if got := gotHeader.Get("Authorization"); got != "Bearer abc" {
+		t.Errorf("Authorization %q, expected Bearer abc", got)
+	}
+	if got := gotHeader.Get("X-Scope-OrgID"); got != "tenant1" {
+		t.Errorf("X-Scope-OrgID %q, expected tenant1", got)
+	}
+
+	got := map[string]sample{}
+	for _, s := range decodeWriteRequest(t, gotBody) {
+		got[s.labels["__name__"]] = s
+	}
+	expect := map[string]sample{
+		"mysql_status_threads_running": {
+			labels: map[string]string{"__name__": "mysql_status_threads_running", "env": "prod"},
+			value:  7,
+			ts:     begin.UnixMilli(),
+		},
+		"mysql_var_max_connections": {
+			labels: map[string]string{"__name__": "mysql_var_max_connections", "env": "group"},
+			value:  100,
+			ts:     begin.UnixMilli(),
+		},
+		"mysql_stmt_digest_count": {
+			labels: map[string]string{"__name__": "mysql_stmt_digest_count", "env": "prod", "digest": "abc"},
+			value:  5,
+			ts:     begin.UnixMilli(),
+		},
+	}
+	deep.CompareUnexportedFields = true
+	defer func() { deep.CompareUnexportedFields = false }()
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrometheusOptions(t *testing.T) {
+	if _, err := NewPrometheus("m1", map[string]string{}, nil, nil); err == nil {
+		t.Error("no error without url, expected an error")
+	}
+
+	opts := map[string]string{
+		"url":          "http://localhost/api/v1/write",
+		"bearer-token": "abc",
+		"username":     "blip",
+	}
+	if _, err := NewPrometheus("m1", opts, nil, nil); err == nil {
+		t.Error("no error with bearer-token and username, expected an error")
+	}
+}
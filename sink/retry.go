@@ -4,6 +4,9 @@ package sink
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,9 +15,19 @@ import (
 )
 
 const (
-	DEFAULT_RETRY_BUFFER_SIZE     = 60
-	DEFAULT_RETRY_SEND_TIMEOUT    = "5s"
-	DEFAULT_RETRY_SEND_RETRY_WAIT = "200ms"
+	DEFAULT_RETRY_BUFFER_SIZE         = 60
+	DEFAULT_RETRY_SEND_TIMEOUT        = "5s"
+	DEFAULT_RETRY_SEND_RETRY_WAIT     = "200ms"
+	DEFAULT_RETRY_SEND_RETRY_MAX_WAIT = "30s"
+	DEFAULT_RETRY_BREAKER_FAILURES    = 0 // disabled
+	DEFAULT_RETRY_BREAKER_COOLDOWN    = "60s"
+)
+
+// Circuit breaker states
+const (
+	BREAKER_CLOSED    = "closed"    // sending normally
+	BREAKER_OPEN      = "open"      // not sending until cooldown
+	BREAKER_HALF_OPEN = "half-open" // sending one metrics to test the real sink
 )
 
 // Retry is a pseudo-sink that provides buffering, serialization, and retry for
@@ -25,29 +38,62 @@ const (
 // This means that, during a long outage of the real sink, Retry drops the oldest
 // metrics and keeps the latest metrics, up to its buffer size, which is configurable.
 //
+// If a Spool is given, metrics pushed off the stack and metrics on the stack
+// when Retry is stopped are written to the spool (on disk), and spooled metrics
+// are replayed when the stack is empty, which means the real sink has recovered.
+//
+// On Send error, Retry waits with exponential backoff and jitter before sending
+// again: retry wait, 2x retry wait, 4x, and so on up to max retry wait, each
+// randomized between 50% and 100% so monitors don't retry in lockstep. If the
+// circuit breaker is enabled (breaker-failures > 0; it's disabled by default),
+// it opens after breaker-failures consecutive errors, and Retry does not send
+// (it only buffers) until the cooldown has passed. Then the breaker is half-open:
+// Retry sends once, and the breaker closes on success or opens again on error.
+//
 // Retry sends SINK_SEND_ERROR events on Send error; the real sink should not.
+// Breaker state, dropped metrics, and the last error are reported by SinkError.
 type Retry struct {
 	sink blip.Sink
 
-	sendMux     *sync.Mutex
-	sending     bool
-	sendTimeout time.Duration
-	retryWait   time.Duration
+	sendMux      *sync.Mutex
+	sending      bool
+	sendTimeout  time.Duration
+	retryWait    time.Duration
+	maxRetryWait time.Duration
 
 	event event.MonitorReceiver
 
+	statusMux       *sync.Mutex
+	breaker         string
+	breakerFailures int           // consecutive errors to open breaker; 0 disables
+	breakerCooldown time.Duration // how long breaker stays open
+	failures        int           // consecutive errors
+	nextSend        time.Time     // backoff: no send before this time
+	openUntil       time.Time     // breaker open until this time
+	errors          uint64        // total send errors
+	dropped         uint64        // metrics pushed off stack and not spooled
+	spooled         uint64        // metrics pushed off stack and spooled
+	lastErr         error
+	lastErrTs       time.Time
+
 	stackMux *sync.Mutex
 	stack    []*blip.Metrics // LIFO
 	max      int
 	top      int
+
+	spool *Spool
 }
 
 type RetryArgs struct {
-	MonitorId     string        // required
-	Sink          blip.Sink     // required
-	BufferSize    uint          // optional; DEFAULT_RETRY_BUFFER_SIZE
-	SendTimeout   time.Duration // optional; DEFAULT_RETRY_SEND_TIMEOUT
-	SendRetryWait time.Duration // optional; DEFAULT_RETRY_SEND_RETRY_WAIT
+	MonitorId        string        // required
+	Sink             blip.Sink     // required
+	BufferSize       uint          // optional; DEFAULT_RETRY_BUFFER_SIZE
+	SendTimeout      time.Duration // optional; DEFAULT_RETRY_SEND_TIMEOUT
+	SendRetryWait    time.Duration // optional; DEFAULT_RETRY_SEND_RETRY_WAIT
+	SendRetryMaxWait time.Duration // optional; DEFAULT_RETRY_SEND_RETRY_MAX_WAIT
+	BreakerFailures  int           // optional; 0 (default) disables breaker
+	BreakerCooldown  time.Duration // optional; DEFAULT_RETRY_BREAKER_COOLDOWN
+	Spool            *Spool        // optional; disk-backed buffer
 }
 
 func NewRetry(args RetryArgs) *Retry {
@@ -69,25 +115,45 @@ func NewRetry(args RetryArgs) *Retry {
 	if args.SendRetryWait == 0 {
 		args.SendRetryWait, _ = time.ParseDuration(DEFAULT_RETRY_SEND_RETRY_WAIT)
 	}
+	if args.SendRetryMaxWait == 0 {
+		args.SendRetryMaxWait, _ = time.ParseDuration(DEFAULT_RETRY_SEND_RETRY_MAX_WAIT)
+	}
+	if args.SendRetryMaxWait < args.SendRetryWait {
+		args.SendRetryMaxWait = args.SendRetryWait
+	}
+	if args.BreakerCooldown == 0 {
+		args.BreakerCooldown, _ = time.ParseDuration(DEFAULT_RETRY_BREAKER_COOLDOWN)
+	}
 
 	rb := &Retry{
 		sink:  args.Sink,
 		event: event.MonitorReceiver{MonitorId: args.MonitorId},
 
-		sendMux:     &sync.Mutex{},
-		sending:     false,
-		sendTimeout: args.SendTimeout,
-		retryWait:   args.SendRetryWait,
+		sendMux:      &sync.Mutex{},
+		sending:      false,
+		sendTimeout:  args.SendTimeout,
+		retryWait:    args.SendRetryWait,
+		maxRetryWait: args.SendRetryMaxWait,
+
+		statusMux:       &sync.Mutex{},
+		breaker:         BREAKER_CLOSED,
+		breakerFailures: args.BreakerFailures,
+		breakerCooldown: args.BreakerCooldown,
 
 		stackMux: &sync.Mutex{},
 		stack:    make([]*blip.Metrics, args.BufferSize),
 		max:      int(args.BufferSize) - 1,
 		top:      -1,
+
+		spool: args.Spool,
 	}
 	blip.Debug("buff %d, send timeout %s", rb.max+1, rb.sendTimeout)
 	return rb
 }
 
+var _ blip.SinkStopper = &Retry{}
+var _ blip.SinkErrorReporter = &Retry{}
+
 // Name returns the name of the real sink, not "retry".
 func (rb *Retry) Name() string {
 	return rb.sink.Name()
@@ -120,30 +186,198 @@ func (rb *Retry) Send(ctx context.Context, m *blip.Metrics) error {
 	ctx2, cancel := context.WithTimeout(ctx, rb.sendTimeout)
 	defer cancel()
 
-	// Process stack from newest to oldest, while we have time
-	n := 0
-	for next := rb.pop(nil); next != nil; next = rb.pop(next) {
-		// Stop when either context is cancelled
-		select {
-		case <-ctx2.Done():
+	for {
+		// Process stack from newest to oldest, while we have time
+		for next := rb.pop(nil); next != nil; next = rb.pop(next) {
+			// Wait for backoff and breaker; stop when either context is cancelled
+			if !rb.ready(ctx2) {
+				return nil
+			}
+
+			// Send next oldest metrics
+			if err := rb.send(ctx, next); err != nil {
+				next = nil // don't pop metrics; retry stack from top down
+			}
+		}
+
+		// Stack is empty, so the real sink is ok. Replay spooled metrics,
+		// one at a time so new metrics pushed on the stack are sent first.
+		if rb.spool == nil {
 			return nil
-		default:
 		}
+		spooled, file, err := rb.spool.Next()
+		if err != nil {
+			rb.event.Errorf(event.SINK_SEND_ERROR, "spool: %s", err)
+			return nil
+		}
+		if spooled == nil {
+			return nil // spool empty
+		}
+		if !rb.ready(ctx2) {
+			return nil
+		}
+		if err := rb.send(ctx, spooled); err != nil {
+			return nil // try again on next Send
+		}
+		if err := rb.spool.Remove(file); err != nil {
+			rb.event.Errorf(event.SINK_SEND_ERROR, "spool: %s", err)
+			return nil
+		}
+	}
+}
+
+// ready returns true when the next metrics can be sent. It waits for the backoff,
+// if any, and returns false if the breaker is open or ctx will be done before
+// the backoff.
+func (rb *Retry) ready(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
 
-		// Throttle between send, except on first send
-		if n > 0 {
-			time.Sleep(rb.retryWait)
+	rb.statusMux.Lock()
+	now := time.Now()
+	if rb.breaker == BREAKER_OPEN {
+		if now.Before(rb.openUntil) {
+			rb.statusMux.Unlock()
+			return false // breaker open; only buffering
 		}
-		n += 1
+		rb.breaker = BREAKER_HALF_OPEN // send once to test real sink
+		blip.Debug("%s breaker half-open", rb.event.MonitorId)
+	}
+	wait := rb.nextSend.Sub(now)
+	rb.statusMux.Unlock()
+
+	if wait <= 0 {
+		return true
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		return false // can't send before timeout; try again on next Send
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
-		// Send next oldest metrics
-		if err := rb.sink.Send(ctx, next); err != nil {
-			rb.event.Errorf(event.SINK_SEND_ERROR, err.Error())
-			next = nil // don't pop metrics; retry stack from top down
+// send sends metrics to the real sink and updates backoff and breaker state.
+func (rb *Retry) send(ctx context.Context, m *blip.Metrics) error {
+	err := rb.sink.Send(ctx, m)
+
+	rb.statusMux.Lock()
+	defer rb.statusMux.Unlock()
+	now := time.Now()
+
+	if err == nil {
+		if rb.breaker != BREAKER_CLOSED {
+			rb.event.Sendf(event.SINK_BREAKER_CLOSED, "%s sink recovered after %d errors", rb.sink.Name(), rb.failures)
 		}
+		rb.breaker = BREAKER_CLOSED
+		rb.failures = 0
+		rb.nextSend = now.Add(rb.retryWait) // throttle between sends
+		return nil
+	}
+
+	rb.event.Errorf(event.SINK_SEND_ERROR, err.Error())
+	rb.errors++
+	rb.failures++
+	rb.lastErr = err
+	rb.lastErrTs = now
+
+	// Exponential backoff with jitter: retryWait * 2^(failures-1), capped at
+	// maxRetryWait, then randomized between 50% and 100%
+	wait := rb.maxRetryWait
+	if shift := rb.failures - 1; shift < 32 {
+		if d := rb.retryWait << shift; d > 0 && d < wait {
+			wait = d
+		}
+	}
+	rb.nextSend = now.Add(jitter(wait))
+
+	if rb.breaker == BREAKER_HALF_OPEN || (rb.breakerFailures > 0 && rb.failures >= rb.breakerFailures && rb.breaker == BREAKER_CLOSED) {
+		rb.breaker = BREAKER_OPEN
+		rb.openUntil = now.Add(rb.breakerCooldown + time.Duration(rand.Int63n(int64(rb.breakerCooldown/4)+1)))
+		rb.event.Errorf(event.SINK_BREAKER_OPEN, "%s sink breaker open after %d errors, until %s: %s",
+			rb.sink.Name(), rb.failures, rb.openUntil.Format(time.RFC3339), err)
 	}
+	return err
+}
 
-	return nil
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// SinkError returns breaker state, dropped metrics, and the last error if
+// the real sink is failing. It returns an empty string if the real sink is ok.
+// It implements blip.SinkErrorReporter.
+func (rb *Retry) SinkError() string {
+	rb.statusMux.Lock()
+	defer rb.statusMux.Unlock()
+	msg := []string{}
+	if rb.breaker != BREAKER_CLOSED {
+		if rb.breaker == BREAKER_OPEN {
+			msg = append(msg, fmt.Sprintf("breaker open until %s", rb.openUntil.Format(time.RFC3339)))
+		} else {
+			msg = append(msg, "breaker "+rb.breaker)
+		}
+	}
+	if rb.dropped > 0 || rb.spooled > 0 {
+		msg = append(msg, fmt.Sprintf("%d dropped, %d spooled", rb.dropped, rb.spooled))
+	}
+	if rb.failures > 0 {
+		msg = append(msg, fmt.Sprintf("%d consecutive errors, last at %s: %s", rb.failures, rb.lastErrTs.Format(time.RFC3339), rb.lastErr))
+	}
+	return strings.Join(msg, "; ")
+}
+
+// BlipMetrics returns Retry metrics for the blip domain. It implements
+// blipmetrics.Source.
+func (rb *Retry) BlipMetrics() []blip.MetricValue {
+	rb.stackMux.Lock()
+	depth := rb.top + 1
+	rb.stackMux.Unlock()
+
+	rb.statusMux.Lock()
+	defer rb.statusMux.Unlock()
+	open := 0.0
+	if rb.breaker != BREAKER_CLOSED {
+		open = 1
+	}
+	group := map[string]string{"sink": rb.sink.Name()}
+	return []blip.MetricValue{
+		{Name: "sink_retry_errors", Type: blip.COUNTER, Value: float64(rb.errors), Group: group},
+		{Name: "sink_buffer_depth", Type: blip.GAUGE, Value: float64(depth), Group: group},
+		{Name: "sink_dropped", Type: blip.COUNTER, Value: float64(rb.dropped), Group: group},
+		{Name: "sink_spooled", Type: blip.COUNTER, Value: float64(rb.spooled), Group: group},
+		{Name: "sink_breaker_open", Type: blip.GAUGE, Value: open, Group: group},
+	}
+}
+
+// Stop writes metrics on the stack to the spool, if any, so they're not lost
+// when the monitor stops, then stops the real sink if it implements
+// blip.SinkStopper. It implements blip.SinkStopper.
+func (rb *Retry) Stop() {
+	if rb.spool != nil {
+		rb.stackMux.Lock()
+		for rb.top > -1 {
+			if err := rb.spool.Write(rb.stack[rb.top]); err != nil {
+				rb.event.Errorf(event.SINK_SEND_ERROR, "spool: %s", err)
+			}
+			rb.stack[rb.top] = nil
+			rb.top--
+		}
+		rb.stackMux.Unlock()
+	}
+	if stopper, ok := rb.sink.(blip.SinkStopper); ok {
+		stopper.Stop()
+	}
 }
 
 func (rb *Retry) push(m *blip.Metrics) {
@@ -152,7 +386,23 @@ func (rb *Retry) push(m *blip.Metrics) {
 	if rb.top < rb.max {
 		rb.top++
 	} else {
-		// Push down stack (push off oldest metrics)
+		// Push down stack (push off oldest metrics), saving oldest metrics
+		// to the spool if enabled
+		spooled := false
+		if rb.spool != nil {
+			if err := rb.spool.Write(rb.stack[0]); err != nil {
+				rb.event.Errorf(event.SINK_SEND_ERROR, "spool: %s", err)
+			} else {
+				spooled = true
+			}
+		}
+		rb.statusMux.Lock()
+		if spooled {
+			rb.spooled++
+		} else {
+			rb.dropped++
+		}
+		rb.statusMux.Unlock()
 		copy(rb.stack, rb.stack[1:])
 	}
 	rb.stack[rb.top] = m
@@ -5,6 +5,7 @@ package sink
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -196,3 +197,75 @@ func TestRetryPopMiddle(t *testing.T) {
 	expect = []string{"2", "3", "", ""}
 	assert.Equal(t, expect, got)
 }
+
+func TestRetryBreaker(t *testing.T) {
+	sent := []string{}
+	var sendErr error
+	mockSink := mock.Sink{
+		SendFunc: func(ctx context.Context, m *blip.Metrics) error {
+			sent = append(sent, m.Level)
+			return sendErr
+		},
+	}
+	rb := NewRetry(RetryArgs{
+		MonitorId:        "m1",
+		Sink:             mockSink,
+		BufferSize:       2,
+		SendTimeout:      time.Second,
+		SendRetryWait:    time.Millisecond,
+		SendRetryMaxWait: time.Millisecond,
+		BreakerFailures:  2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+
+	// Sink is down: breaker opens after 2 errors, so Send returns without
+	// sending until cooldown
+	sendErr = fmt.Errorf("rate limited")
+	rb.Send(context.Background(), &blip.Metrics{Level: "1"})
+	assert.Equal(t, []string{"1", "1"}, sent)
+	rb.Send(context.Background(), &blip.Metrics{Level: "2"})
+	rb.Send(context.Background(), &blip.Metrics{Level: "3"}) // pushes 1 off stack
+	assert.Equal(t, []string{"1", "1"}, sent)
+
+	status := rb.SinkError()
+	for _, s := range []string{"breaker open until", "1 dropped, 0 spooled", "2 consecutive errors", "rate limited"} {
+		if !strings.Contains(status, s) {
+			t.Errorf("SinkError %q does not contain %q", status, s)
+		}
+	}
+
+	// After cooldown (+25% max jitter), breaker is half-open: first send
+	// succeeds, so breaker closes and the rest of the stack is sent
+	time.Sleep(70 * time.Millisecond)
+	sendErr = nil
+	sent = []string{}
+	rb.Send(context.Background(), &blip.Metrics{Level: "4"})
+	assert.Equal(t, []string{"4", "3"}, sent)
+	assert.Equal(t, "2 dropped, 0 spooled", rb.SinkError())
+}
+
+func TestRetryBreakerDisabled(t *testing.T) {
+	// Breaker is disabled by default: Retry keeps retrying (with backoff)
+	// no matter how many consecutive errors
+	sendErr := fmt.Errorf("rate limited")
+	mockSink := mock.Sink{
+		SendFunc: func(ctx context.Context, m *blip.Metrics) error {
+			return sendErr
+		},
+	}
+	rb := NewRetry(RetryArgs{
+		MonitorId:        "m1",
+		Sink:             mockSink,
+		SendTimeout:      50 * time.Millisecond,
+		SendRetryWait:    time.Millisecond,
+		SendRetryMaxWait: time.Millisecond,
+	})
+	rb.Send(context.Background(), &blip.Metrics{Level: "1"})
+	status := rb.SinkError()
+	if strings.Contains(status, "breaker") {
+		t.Errorf("SinkError %q reports breaker, expected breaker disabled", status)
+	}
+	if !strings.Contains(status, "rate limited") {
+		t.Errorf("SinkError %q does not contain send error", status)
+	}
+}
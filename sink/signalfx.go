@@ -83,12 +83,51 @@ func (s *SignalFx) Send(ctx context.Context, m *blip.Metrics) error {
 		status.Monitor(s.monitorId, "signalfx", "last sent %d metrics at %s", n, time.Now())
 	}()
 
+	dp, err := s.datapoints(m, s.dim)
+	if err != nil {
+		return err
+	}
+	n = len(dp)
+
+	// Send metrics to SFX. The SFX client handles everything; we just pass
+	// it data points.
+	return s.sfxSink.AddDatapoints(ctx, dp)
+}
+
+// SendBatch sends metrics from many monitors in one request. Each monitor's
+// tags are its data point dimensions. Metrics that cannot be converted are
+// returned as BatchItemErrors. It implements BatchSender.
+func (s *SignalFx) SendBatch(ctx context.Context, items []BatchItem) error {
+	var all []*datapoint.Datapoint
+	itemErrs := BatchItemErrors{}
+	for _, item := range items {
+		dp, err := s.datapoints(item.Metrics, item.Tags)
+		if err != nil {
+			itemErrs[item.Metrics.MonitorId] = err
+			continue
+		}
+		all = append(all, dp...)
+	}
+	if len(all) > 0 {
+		if err := s.sfxSink.AddDatapoints(ctx, all); err != nil {
+			return err
+		}
+	}
+	if len(itemErrs) > 0 {
+		return itemErrs
+	}
+	return nil
+}
+
+// datapoints converts Blip metrics to SFX data points with the given dimensions.
+func (s *SignalFx) datapoints(m *blip.Metrics, dim map[string]string) ([]*datapoint.Datapoint, error) {
 	// Pre-alloc SFX data points
+	n := 0
 	for _, metrics := range m.Values {
 		n += len(metrics)
 	}
 	if n == 0 {
-		return fmt.Errorf("no Blip metrics were collected")
+		return nil, fmt.Errorf("no Blip metrics were collected")
 	}
 	dp := make([]*datapoint.Datapoint, n)
 	n = 0
@@ -115,9 +154,9 @@ func (s *SignalFx) Send(ctx context.Context, m *blip.Metrics) error {
 			// Convert Blip metric type to SFX metric type
 			switch metrics[i].Type {
 			case blip.COUNTER:
-				dp[n] = sfxclient.CumulativeF(name, s.dim, metrics[i].Value)
+				dp[n] = sfxclient.CumulativeF(name, dim, metrics[i].Value)
 			case blip.GAUGE:
-				dp[n] = sfxclient.GaugeF(name, s.dim, metrics[i].Value)
+				dp[n] = sfxclient.GaugeF(name, dim, metrics[i].Value)
 			default:
 				// SFX doesn't support this Blip metric type, so skip it
 				continue METRICS // @todo error?
@@ -146,12 +185,10 @@ func (s *SignalFx) Send(ctx context.Context, m *blip.Metrics) error {
 
 	// This shouldn't happen: >0 Blip metrics in but =0 SFX data points out
 	if n == 0 {
-		return fmt.Errorf("no SignalFx data points after processing %d Blip metrics", len(m.Values))
+		return nil, fmt.Errorf("no SignalFx data points after processing %d Blip metrics", len(m.Values))
 	}
 
-	// Send metrics to SFX. The SFX client handles everything; we just pass
-	// it data points.
-	return s.sfxSink.AddDatapoints(ctx, dp[0:n])
+	return dp[0:n], nil
 }
 
 func (s *SignalFx) Name() string {
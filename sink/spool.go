@@ -0,0 +1,217 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DEFAULT_SPOOL_MAX_BYTES = "100M"
+	DEFAULT_SPOOL_MAX_AGE   = "24h"
+
+	spoolFileExt = ".json"
+)
+
+// Spool is a disk-backed buffer for Retry. Retry writes metrics to the spool
+// when they're pushed off its in-memory stack (overflow) and when it's stopped,
+// and it replays spooled metrics, newest first, when the real sink recovers.
+//
+// Each blip.Metrics is one JSON file in the spool directory named by
+// Metrics.Begin (Unix nanoseconds), so file names sort by time. The oldest
+// files are removed when the spool exceeds max bytes, and files older than
+// max age are removed, not replayed.
+//
+// The spool directory is read once, on first use (it can have files from a
+// previous run); then the spool keeps its file names and total size in memory,
+// so Write and Next don't read the directory.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	// --
+	*sync.Mutex
+	loaded bool
+	files  []spoolFile // oldest to newest
+	total  int64       // bytes
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// NewSpool makes a spool in dir, which is created if it does not exist.
+// Dir should be unique to the monitor and sink: spool-dir/monitorId/sinkName.
+func NewSpool(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("cannot create spool dir: %s", err)
+	}
+	return &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		Mutex:    &sync.Mutex{},
+	}, nil
+}
+
+// Write saves metrics to the spool, then removes the oldest spooled metrics
+// if the spool is larger than max bytes.
+func (s *Spool) Write(m *blip.Metrics) error {
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	// Write to temp file then rename so Next never reads a partial file
+	name := strconv.FormatInt(m.Begin.UnixNano(), 10) + spoolFileExt
+	file := filepath.Join(s.dir, name)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, bytes, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+	s.add(name, int64(len(bytes)))
+
+	for s.total > s.maxBytes && len(s.files) > 0 {
+		blip.Debug("spool %s full, removing %s", s.dir, s.files[0].name)
+		if err := os.Remove(filepath.Join(s.dir, s.files[0].name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.del(0)
+	}
+	return nil
+}
+
+// Next returns the newest spooled metrics and its file name, or nil if the spool
+// is empty. Metrics older than max age are removed. The caller must call Remove
+// with the file name after sending the metrics.
+func (s *Spool) Next() (*blip.Metrics, string, error) {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.load(); err != nil {
+		return nil, "", err
+	}
+
+	oldest := time.Now().Add(-s.maxAge).UnixNano()
+	for i := len(s.files) - 1; i >= 0; i-- {
+		name := s.files[i].name
+		ts, _ := strconv.ParseInt(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+		if ts < oldest {
+			// This and all older files are expired
+			for _, f := range s.files[:i+1] {
+				blip.Debug("spool %s: removing expired %s", s.dir, f.name)
+				os.Remove(filepath.Join(s.dir, f.name))
+				s.total -= f.size
+			}
+			s.files = s.files[i+1:]
+			return nil, "", nil
+		}
+
+		bytes, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, "", err
+		}
+		m := &blip.Metrics{}
+		if err := json.Unmarshal(bytes, m); err != nil {
+			blip.Debug("spool %s: removing invalid %s: %s", s.dir, name, err)
+			os.Remove(filepath.Join(s.dir, name))
+			s.del(i)
+			continue
+		}
+		return m, name, nil
+	}
+	return nil, "", nil
+}
+
+// Remove removes the spooled metrics file returned by Next.
+func (s *Spool) Remove(file string) error {
+	s.Lock()
+	defer s.Unlock()
+	if i, ok := s.find(file); ok {
+		s.del(i)
+	}
+	err := os.Remove(filepath.Join(s.dir, file))
+	if os.IsNotExist(err) {
+		return nil // removed by Write because spool was full
+	}
+	return err
+}
+
+// load reads spool file names and sizes from the spool dir, once. The caller
+// must lock the spool.
+func (s *Spool) load() error {
+	if s.loaded {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	s.files = []spoolFile{}
+	s.total = 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), spoolFileExt) {
+			continue // ignore .tmp and other files
+		}
+		s.files = append(s.files, spoolFile{name: e.Name(), size: e.Size()})
+		s.total += e.Size()
+	}
+	sort.Slice(s.files, func(i, j int) bool { return spoolLess(s.files[i].name, s.files[j].name) })
+	s.loaded = true
+	return nil
+}
+
+// find returns the index of the file and true, or the index where the file
+// would be inserted and false.
+func (s *Spool) find(name string) (int, bool) {
+	i := sort.Search(len(s.files), func(i int) bool { return !spoolLess(s.files[i].name, name) })
+	return i, i < len(s.files) && s.files[i].name == name
+}
+
+// add adds or replaces a file in sort order. Usually the file is the newest,
+// but Retry.Stop writes its stack newest to oldest.
+func (s *Spool) add(name string, size int64) {
+	i, ok := s.find(name)
+	if ok {
+		s.total += size - s.files[i].size
+		s.files[i].size = size
+		return
+	}
+	s.files = append(s.files, spoolFile{})
+	copy(s.files[i+1:], s.files[i:])
+	s.files[i] = spoolFile{name: name, size: size}
+	s.total += size
+}
+
+func (s *Spool) del(i int) {
+	s.total -= s.files[i].size
+	s.files = append(s.files[:i], s.files[i+1:]...)
+}
+
+// spoolLess sorts file names numerically: file names are Unix nanoseconds.
+func spoolLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
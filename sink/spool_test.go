@@ -0,0 +1,227 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/test/mock"
+)
+
+// spoolDir returns the spool files on disk, and checks that the spool's
+// in-memory file names and total size match.
+func spoolDir(t *testing.T, spool *Spool) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(spool.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{}
+	var total int64
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), spoolFileExt) {
+			files = append(files, e.Name())
+			total += e.Size()
+		}
+	}
+	spool.Lock()
+	defer spool.Unlock()
+	mem := []string{}
+	for _, f := range spool.files {
+		mem = append(mem, f.name)
+	}
+	sort.Strings(files) // same length, so same as numeric sort
+	if diff := deep.Equal(mem, files); diff != nil {
+		t.Errorf("spool files in memory != on disk: %v", diff)
+	}
+	if spool.total != total {
+		t.Errorf("spool total %d bytes, expected %d", spool.total, total)
+	}
+	return files
+}
+
+func TestSpool(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 1024*1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for i := 1; i <= 3; i++ {
+		m := &blip.Metrics{
+			Begin: now.Add(time.Duration(i) * time.Second),
+			Level: fmt.Sprintf("%d", i),
+			Values: map[string][]blip.MetricValue{
+				"status.global": {{Name: "queries", Type: blip.COUNTER, Value: float64(i)}},
+			},
+		}
+		if err := spool.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Newest first
+	got := []string{}
+	for {
+		m, file, err := spool.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m == nil {
+			break
+		}
+		got = append(got, m.Level)
+		if m.Values["status.global"][0].Type != blip.COUNTER {
+			t.Errorf("metric type %d, expected %d", m.Values["status.global"][0].Type, blip.COUNTER)
+		}
+		if err := spool.Remove(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := deep.Equal(got, []string{"3", "2", "1"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSpoolLimits(t *testing.T) {
+	// Max bytes: each file is ~120 bytes, so only the newest fits
+	spool, err := NewSpool(t.TempDir(), 150, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	spool.Write(&blip.Metrics{Begin: now.Add(-2 * time.Second), Level: "old"})
+	spool.Write(&blip.Metrics{Begin: now.Add(-1 * time.Second), Level: "new"})
+	files := spoolDir(t, spool)
+	if len(files) != 1 {
+		t.Errorf("got %d files, expected 1: %v", len(files), files)
+	}
+	m, _, _ := spool.Next()
+	if m == nil || m.Level != "new" {
+		t.Errorf("got %+v, expected Level=new", m)
+	}
+
+	// Max age: expired metrics are removed, not returned
+	spool, err = NewSpool(t.TempDir(), 1024*1024, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.Write(&blip.Metrics{Begin: now.Add(-2 * time.Minute), Level: "expired"})
+	m, _, err = spool.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != nil {
+		t.Errorf("got %+v, expected nil", m)
+	}
+	files = spoolDir(t, spool)
+	if len(files) != 0 {
+		t.Errorf("got %d files, expected 0: %v", len(files), files)
+	}
+}
+
+func TestRetrySpool(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 1024*1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := []string{}
+	var sendErr error
+	mockSink := mock.Sink{
+		SendFunc: func(ctx context.Context, m *blip.Metrics) error {
+			if sendErr == nil {
+				sent = append(sent, m.Level)
+			}
+			return sendErr
+		},
+	}
+	rb := NewRetry(RetryArgs{
+		MonitorId:        "m1",
+		Sink:             mockSink,
+		BufferSize:       2,
+		SendTimeout:      200 * time.Millisecond,
+		SendRetryWait:    5 * time.Millisecond,
+		SendRetryMaxWait: 5 * time.Millisecond,
+		Spool:            spool,
+	})
+
+	// Sink is down: stack holds 2 metrics, so metrics 1 and 2 are spooled
+	// on overflow
+	sendErr = fmt.Errorf("sink down")
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		rb.Send(context.Background(), &blip.Metrics{Begin: now.Add(time.Duration(i) * time.Second), Level: fmt.Sprintf("%d", i)})
+	}
+	if diff := deep.Equal(stack(rb), []string{"3", "4"}); diff != nil {
+		t.Error(diff)
+	}
+	files := spoolDir(t, spool)
+	if len(files) != 2 {
+		t.Errorf("got %d spool files, expected 2", len(files))
+	}
+
+	// Stop spools metrics on the stack
+	rb.Stop()
+	if diff := deep.Equal(stack(rb), []string{"", ""}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Sink recovers: new metrics first, then spooled metrics newest first
+	sendErr = nil
+	rb.Send(context.Background(), &blip.Metrics{Begin: now.Add(5 * time.Second), Level: "5"})
+	if diff := deep.Equal(sent, []string{"5", "4", "3", "2", "1"}); diff != nil {
+		t.Error(diff)
+	}
+	files = spoolDir(t, spool)
+	if len(files) != 0 {
+		t.Errorf("got %d spool files, expected 0", len(files))
+	}
+}
+
+func TestSpoolLoad(t *testing.T) {
+	// Files from a previous run are loaded on first use
+	dir := t.TempDir()
+	spool, err := NewSpool(dir, 1024*1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	spool.Write(&blip.Metrics{Begin: now.Add(1 * time.Second), Level: "1"})
+	spool.Write(&blip.Metrics{Begin: now.Add(3 * time.Second), Level: "3"})
+
+	spool, err = NewSpool(dir, 1024*1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.Write(&blip.Metrics{Begin: now.Add(2 * time.Second), Level: "2"}) // out of order, like Retry.Stop
+	if files := spoolDir(t, spool); len(files) != 3 {
+		t.Errorf("got %d files, expected 3: %v", len(files), files)
+	}
+
+	got := []string{}
+	for {
+		m, file, err := spool.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m == nil {
+			break
+		}
+		got = append(got, m.Level)
+		spool.Remove(file)
+	}
+	if diff := deep.Equal(got, []string{"3", "2", "1"}); diff != nil {
+		t.Error(diff)
+	}
+	spoolDir(t, spool)
+}
@@ -0,0 +1,323 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sink/tr"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	DEFAULT_STATSD_ADDR = "127.0.0.1:8125"
+	DEFAULT_STATSD_MTU  = 1432 // max UDP payload without IP fragmentation on a typical 1500 MTU network
+)
+
+// StatsD sends metrics to StatsD or a DogStatsD (Datadog agent) server over UDP
+// or a Unix datagram socket.
+//
+// StatsD counters are deltas, but Blip counters are cumulative, so StatsD reports
+// the difference between the current and last value of each counter. The first
+// value of each counter is not reported because there is no last value. Last
+// values are saved only after the packet with the counter is sent, so a failed
+// send that Retry sends again reports only the deltas that were not sent.
+type StatsD struct {
+	monitorId string
+	tags      string              // monitor.tags as DogStatsD tags: "k:v,k:v"
+	tr        tr.DomainTranslator // statsd.metric-translator
+	prefix    string              // statsd.metric-prefix
+	// --
+	network     string
+	addr        string
+	mtu         int
+	dogstatsd   bool
+	*sync.Mutex // guards conn: Send and Stop
+	conn        net.Conn
+	last        map[string]statsdCounter // counter line (without value) => last value
+}
+
+type statsdCounter struct {
+	value float64
+	ts    time.Time // Metrics.Begin
+	line  bool      // has a line (delta) to send
+}
+
+func NewStatsD(monitorId string, opts, tags map[string]string) (*StatsD, error) {
+	s := &StatsD{
+		monitorId: monitorId,
+		// --
+		network: "udp",
+		addr:    DEFAULT_STATSD_ADDR,
+		mtu:     DEFAULT_STATSD_MTU,
+		Mutex:   &sync.Mutex{},
+		last:    map[string]statsdCounter{},
+	}
+
+	for k, v := range opts {
+		switch k {
+		case "addr":
+			if strings.HasPrefix(v, "unix://") {
+				s.network = "unixgram"
+				s.addr = strings.TrimPrefix(v, "unix://")
+			} else {
+				s.addr = v
+			}
+		case "mtu":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid statsd sink mtu: %s: %s", v, err)
+			}
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid statsd sink mtu: %d: must be greater than zero", n)
+			}
+			s.mtu = n
+		case "dogstatsd":
+			s.dogstatsd = blip.Bool(v)
+		case "metric-translator":
+			tr, err := tr.Make(v)
+			if err != nil {
+				return nil, err
+			}
+			s.tr = tr
+		case "metric-prefix":
+			if v == "" {
+				return nil, fmt.Errorf("statsd sink metric-prefix is empty string; value required when option is specified")
+			}
+			s.prefix = v
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.dogstatsd {
+		s.tags = statsdTags(tags)
+	}
+
+	return s, nil
+}
+
+var _ blip.SinkStopper = &StatsD{}
+
+func (s *StatsD) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	s.Lock()
+	defer s.Unlock()
+	status.Monitor(s.monitorId, "statsd", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "statsd", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "statsd", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	lines, counters := s.lines(m)
+	s.save(nil, counters) // first values and resets, which have no lines
+	n = len(lines)
+	if n == 0 {
+		return // success (nothing to send)
+	}
+
+	// Connect on first send, or reconnect after an error, so that a StatsD
+	// server (or socket) that's not ready when Blip starts is not fatal
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			lerr = err
+			return // implicit lerr
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	// Batch lines into packets no larger than the MTU. A single line larger
+	// than the MTU is sent by itself. Counter values in each packet are saved
+	// after the packet is sent, so if a later packet fails, Retry doesn't
+	// send the same deltas again.
+	packet := make([]byte, 0, s.mtu)
+	first := 0 // first line in packet
+	for i, l := range lines {
+		if len(packet) > 0 && len(packet)+1+len(l.line) > s.mtu {
+			if lerr = s.write(packet); lerr != nil {
+				return // implicit lerr
+			}
+			s.save(lines[first:i], counters)
+			packet = packet[:0]
+			first = i
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, l.line...)
+	}
+	if lerr = s.write(packet); lerr != nil {
+		return // implicit lerr
+	}
+	s.save(lines[first:], counters)
+	return // success
+}
+
+// Stop closes the connection. It implements blip.SinkStopper.
+func (s *StatsD) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *StatsD) Name() string {
+	return "statsd"
+}
+
+func (s *StatsD) write(packet []byte) error {
+	if _, err := s.conn.Write(packet); err != nil {
+		s.conn.Close()
+		s.conn = nil // reconnect on next Send
+		return err
+	}
+	return nil
+}
+
+// statsdLine is one line to send. If the line is a counter delta, counter is
+// its key in StatsD.last.
+type statsdLine struct {
+	line    string
+	counter string
+}
+
+// save saves counter values returned by lines for the lines that were sent.
+// If sent is nil, it saves counter values that have no line (first values and
+// resets), which can be saved before sending.
+func (s *StatsD) save(sent []statsdLine, counters map[string]statsdCounter) {
+	if sent == nil {
+		for key, c := range counters {
+			if !c.line {
+				s.last[key] = c
+			}
+		}
+		return
+	}
+	for _, l := range sent {
+		if l.counter != "" {
+			s.last[l.counter] = counters[l.counter]
+		}
+	}
+}
+
+// lines returns a StatsD line for each metric: "name:value|g" or "name:delta|c",
+// plus "|#tags" if DogStatsD. It also returns the counter values to save when
+// their lines are sent.
+func (s *StatsD) lines(m *blip.Metrics) ([]statsdLine, map[string]statsdCounter) {
+	lines := []statsdLine{}
+	counters := map[string]statsdCounter{}
+	for domain := range m.Values {
+		metrics := m.Values[domain]
+		for i := range metrics {
+
+			// Set full metric name: translator (if any) else Blip standard,
+			// then prefix (if any)
+			var name string
+			if s.tr == nil {
+				name = domain + "." + metrics[i].Name
+			} else {
+				name = s.tr.Translate(domain, metrics[i].Name)
+			}
+			name = statsdName(s.prefix + name)
+
+			// Groups are DogStatsD tags, else group values are appended to the
+			// metric name because plain StatsD has no tags
+			var tags string
+			if s.dogstatsd {
+				tags = s.tags
+				if len(metrics[i].Group) > 0 {
+					if tags != "" {
+						tags += ","
+					}
+					tags += statsdTags(metrics[i].Group)
+				}
+			} else if len(metrics[i].Group) > 0 {
+				keys := make([]string, 0, len(metrics[i].Group))
+				for k := range metrics[i].Group {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					name += "." + statsdName(metrics[i].Group[k])
+				}
+			}
+			if tags != "" {
+				tags = "|#" + tags
+			}
+
+			value := metrics[i].Value
+			var mtype, counter string
+			switch metrics[i].Type {
+			case blip.COUNTER:
+				key := name + tags
+				last, ok := s.last[key]
+				if ok && m.Begin.Before(last.ts) {
+					// Older than last sent (Retry sends newest first), so its
+					// delta was already included in the last sent delta
+					continue
+				}
+				counters[key] = statsdCounter{value: value, ts: m.Begin}
+				if !ok || value < last.value {
+					continue // first value or counter reset
+				}
+				value -= last.value
+				mtype = "c"
+				counter = key
+				counters[key] = statsdCounter{value: metrics[i].Value, ts: m.Begin, line: true}
+			case blip.GAUGE, blip.BOOL:
+				mtype = "g"
+				if value < 0 && !s.dogstatsd {
+					// Plain StatsD reads "-1|g" as a decrement, so set gauge to
+					// zero first. DogStatsD doesn't have relative gauges.
+					lines = append(lines, statsdLine{line: name + ":0|g" + tags})
+				}
+			default:
+				continue // StatsD doesn't support this Blip metric type
+			}
+
+			lines = append(lines, statsdLine{
+				line:    name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + mtype + tags,
+				counter: counter,
+			})
+		}
+	}
+	return lines, counters
+}
+
+// statsdName replaces characters that are special in the StatsD line protocol.
+var statsdName = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_").Replace
+
+// statsdTags returns DogStatsD tags "k:v,k:v" sorted by key.
+func statsdTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kv := make([]string, len(keys))
+	for i, k := range keys {
+		kv[i] = statsdName(k) + ":" + strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace(tags[k])
+	}
+	return strings.Join(kv, ",")
+}
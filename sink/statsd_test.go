@@ -0,0 +1,276 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+// readStatsD reads n packets and returns all lines sorted.
+func readStatsD(t *testing.T, conn net.PacketConn, n int) ([]string, []int) {
+	t.Helper()
+	lines := []string{}
+	sizes := []int{}
+	buf := make([]byte, 65535)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < n; i++ {
+		nBytes, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, nBytes)
+		lines = append(lines, strings.Split(string(buf[:nBytes]), "\n")...)
+	}
+	sort.Strings(lines)
+	return lines, sizes
+}
+
+func TestStatsDSend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	opts := map[string]string{
+		"addr":          conn.LocalAddr().String(),
+		"dogstatsd":     "yes",
+		"metric-prefix": "db.",
+	}
+	s, err := NewStatsD("m1", opts, map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &blip.Metrics{
+		Values: map[string][]blip.MetricValue{
+			"status.global": {
+				{Name: "threads_running", Type: blip.GAUGE, Value: 7},
+				{Name: "queries", Type: blip.COUNTER, Value: 100},
+			},
+			"size.database": {
+				{Name: "bytes", Type: blip.GAUGE, Value: 512, Group: map[string]string{"db": "app"}},
+			},
+		},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	// First counter value is not sent because there's no last value
+	got, _ := readStatsD(t, conn, 1)
+	expect := []string{
+		"db.size.database.bytes:512|g|#env:prod,db:app",
+		"db.status.global.threads_running:7|g|#env:prod",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Second send reports counter delta
+	m.Values["status.global"][1].Value = 150
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = readStatsD(t, conn, 1)
+	expect = []string{
+		"db.size.database.bytes:512|g|#env:prod,db:app",
+		"db.status.global.queries:50|c|#env:prod",
+		"db.status.global.threads_running:7|g|#env:prod",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStatsDMTU(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	opts := map[string]string{
+		"addr": conn.LocalAddr().String(),
+		"mtu":  "64",
+	}
+	s, err := NewStatsD("m1", opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each line is "size.database.bytes.dbN:N|g" = 27 bytes, so 2 lines per packet.
+	// Plain StatsD: group values are appended to metric name.
+	values := []blip.MetricValue{}
+	for _, db := range []string{"db1", "db2", "db3", "db4", "db5"} {
+		values = append(values, blip.MetricValue{Name: "bytes", Type: blip.GAUGE, Value: 1, Group: map[string]string{"db": db}})
+	}
+	m := &blip.Metrics{Values: map[string][]blip.MetricValue{"size.database": values}}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, sizes := readStatsD(t, conn, 3)
+	if len(got) != 5 {
+		t.Errorf("got %d lines, expected 5: %v", len(got), got)
+	}
+	if got[0] != "size.database.bytes.db1:1|g" {
+		t.Errorf("got line %s, expected size.database.bytes.db1:1|g", got[0])
+	}
+	for _, n := range sizes {
+		if n > 64 {
+			t.Errorf("packet size %d > mtu 64", n)
+		}
+	}
+}
+
+func TestStatsDRetry(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "statsd.sock")
+	listen := func() net.PacketConn {
+		conn, err := net.ListenPacket("unixgram", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	conn := listen()
+
+	s, err := NewStatsD("m1", map[string]string{"addr": "unix://" + sock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := func(queries float64, begin int64) *blip.Metrics {
+		return &blip.Metrics{
+			Begin: time.Unix(begin, 0),
+			Values: map[string][]blip.MetricValue{
+				"status.global": {{Name: "queries", Type: blip.COUNTER, Value: queries}},
+				"repl":          {{Name: "running", Type: blip.GAUGE, Value: -1}},
+			},
+		}
+	}
+
+	// Negative gauge is set to 0 first because plain StatsD reads -1 as decrement
+	if err := s.Send(context.Background(), metrics(100, 1)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "repl.running:0|g\nrepl.running:-1|g" {
+		t.Errorf("got %q, expected repl.running:0|g then repl.running:-1|g", got)
+	}
+
+	// Send fails (StatsD server gone), so counter delta must not be lost
+	conn.Close()
+	os.Remove(sock)
+	m2 := metrics(150, 2)
+	if err := s.Send(context.Background(), m2); err == nil {
+		t.Fatal("no error sending to closed socket, expected an error")
+	}
+
+	// Retry sends m2 again: counter delta is still 50
+	conn = listen()
+	defer conn.Close()
+	if err := s.Send(context.Background(), m2); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := readStatsD(t, conn, 1)
+	if diff := deep.Equal(got, []string{"repl.running:-1|g", "repl.running:0|g", "status.global.queries:50|c"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Older metrics sent after newer metrics (Retry sends newest first): counter
+	// is not sent and last value is not changed
+	if err := s.Send(context.Background(), metrics(200, 4)); err != nil {
+		t.Fatal(err)
+	}
+	readStatsD(t, conn, 1)
+	if err := s.Send(context.Background(), metrics(180, 3)); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = readStatsD(t, conn, 1)
+	if diff := deep.Equal(got, []string{"repl.running:-1|g", "repl.running:0|g"}); diff != nil {
+		t.Error(diff)
+	}
+	if err := s.Send(context.Background(), metrics(260, 5)); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = readStatsD(t, conn, 1)
+	if diff := deep.Equal(got, []string{"repl.running:-1|g", "repl.running:0|g", "status.global.queries:60|c"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStatsDPartialSend(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "statsd.sock")
+	conn, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD("m1", map[string]string{"addr": "unix://" + sock, "mtu": "100"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	metrics := func(queries float64, huge bool) *blip.Metrics {
+		values := []blip.MetricValue{{Name: "queries", Type: blip.COUNTER, Value: queries}}
+		if huge {
+			// Line larger than the socket buffer, so its packet fails
+			values = append(values, blip.MetricValue{Name: "x", Type: blip.GAUGE, Value: 1,
+				Group: map[string]string{"k": strings.Repeat("a", 1024*1024)}})
+		}
+		return &blip.Metrics{
+			Begin:  time.Unix(1, 0),
+			Values: map[string][]blip.MetricValue{"status.global": values},
+		}
+	}
+
+	if err := s.Send(context.Background(), metrics(100, false)); err != nil { // first value, not sent
+		t.Fatal(err)
+	}
+
+	// First packet (counter delta 50) is sent, second packet fails
+	m := metrics(150, true)
+	m.Begin = time.Unix(2, 0)
+	if err := s.Send(context.Background(), m); err == nil {
+		t.Fatal("no error sending line larger than socket buffer, expected an error")
+	}
+	got, _ := readStatsD(t, conn, 1)
+	if diff := deep.Equal(got, []string{"status.global.queries:50|c"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Retry sends the same metrics again: the counter delta was already sent,
+	// so it's not counted twice
+	m = metrics(150, false)
+	m.Begin = time.Unix(2, 0)
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = readStatsD(t, conn, 1)
+	if diff := deep.Equal(got, []string{"status.global.queries:0|c"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Stop closes the connection, and Send reconnects
+	s.Stop()
+	if s.conn != nil {
+		t.Error("conn not nil after Stop")
+	}
+}
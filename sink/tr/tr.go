@@ -2,7 +2,10 @@ package tr
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cashapp/blip"
 )
@@ -31,13 +34,37 @@ func Exists(name string) bool {
 	return ok
 }
 
+// Make returns the domain translator registered as name. If name has FILE_PREFIX,
+// it loads a YAML translator from the file and registers it as name, so the file
+// is loaded once for all sinks that use it. The file is loaded again if it has
+// been modified since last loaded, so sinks made on reload use the new file.
 func Make(name string) (DomainTranslator, error) {
 	r.Lock()
 	defer r.Unlock()
+	if !strings.HasPrefix(name, FILE_PREFIX) {
+		tr, ok := r.tr[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid domain translator: %s (not registered)", name)
+		}
+		return tr, nil
+	}
+
+	file := strings.TrimPrefix(name, FILE_PREFIX)
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
 	tr, ok := r.tr[name]
-	if !ok {
-		return nil, fmt.Errorf("invalid domain translator: %s (not registered)", name)
+	if ok && fi.ModTime().Equal(r.modTime[name]) {
+		return tr, nil
 	}
+	tr, err = LoadYAML(file)
+	if err != nil {
+		return nil, err
+	}
+	r.tr[name] = tr
+	r.modTime[name] = fi.ModTime()
+	blip.Debug("register domain tr: %s", name)
 	return tr, nil
 }
 
@@ -45,12 +72,14 @@ func Make(name string) (DomainTranslator, error) {
 // instance below.
 type repo struct {
 	*sync.Mutex
-	tr map[string]DomainTranslator
+	tr      map[string]DomainTranslator
+	modTime map[string]time.Time // FILE_PREFIX translators
 }
 
 // Internal package instance of repo that holds all collector factories registered
 // by calls to Register, which includes the built-in factories.
 var r = &repo{
-	Mutex: &sync.Mutex{},
-	tr:    map[string]DomainTranslator{},
+	Mutex:   &sync.Mutex{},
+	tr:      map[string]DomainTranslator{},
+	modTime: map[string]time.Time{},
 }
@@ -0,0 +1,136 @@
+// Copyright 2022 Block, Inc.
+
+package tr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FILE_PREFIX is the metric-translator value prefix for a YAML translator file:
+// "metric-translator: file:/etc/blip/tr.yaml".
+const FILE_PREFIX = "file:"
+
+// ANY_DOMAIN is the YAML translator domain that applies to domains without rules.
+const ANY_DOMAIN = "*"
+
+// YAMLFile is the YAML translator file.
+type YAMLFile struct {
+	Domains map[string]YAMLDomain `yaml:"domains"`
+}
+
+// YAMLDomain are the rename rules for one domain. An exact match in Metrics is
+// the final name; other rules are not applied. Else, Replace rules are applied
+// in order, then Case, then Prefix and Suffix are added. "{domain}" in Prefix
+// or Suffix is replaced with the domain name.
+type YAMLDomain struct {
+	Metrics map[string]string `yaml:"metrics,omitempty"`
+	Replace []YAMLReplace     `yaml:"replace,omitempty"`
+	Case    string            `yaml:"case,omitempty"`
+	Prefix  string            `yaml:"prefix,omitempty"`
+	Suffix  string            `yaml:"suffix,omitempty"`
+}
+
+// YAMLReplace is a regular expression replace rule. With can reference submatches
+// like $1 or ${1}.
+type YAMLReplace struct {
+	Regex string `yaml:"regex"`
+	With  string `yaml:"with"`
+}
+
+// YAML is a DomainTranslator that renames metrics by rules in a YAML file.
+// Metrics from domains without rules (and without ANY_DOMAIN rules) are named
+// "domain.metric".
+type YAML struct {
+	domains map[string]yamlDomain
+}
+
+var _ DomainTranslator = YAML{}
+
+type yamlDomain struct {
+	metrics map[string]string
+	replace []yamlReplace
+	toCase  func(string) string
+	prefix  string
+	suffix  string
+}
+
+type yamlReplace struct {
+	re   *regexp.Regexp
+	with string
+}
+
+// LoadYAML loads a YAML translator from file.
+func LoadYAML(file string) (YAML, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return YAML{}, err
+	}
+	var f YAMLFile
+	if err := yaml.UnmarshalStrict(bytes, &f); err != nil {
+		return YAML{}, fmt.Errorf("invalid YAML translator file %s: %s", file, err)
+	}
+	tr, err := NewYAML(f)
+	if err != nil {
+		return YAML{}, fmt.Errorf("invalid YAML translator file %s: %s", file, err)
+	}
+	return tr, nil
+}
+
+// NewYAML makes a YAML translator from rules already loaded.
+func NewYAML(f YAMLFile) (YAML, error) {
+	tr := YAML{
+		domains: make(map[string]yamlDomain, len(f.Domains)),
+	}
+	for domain, rules := range f.Domains {
+		d := yamlDomain{
+			metrics: rules.Metrics,
+			replace: make([]yamlReplace, len(rules.Replace)),
+			prefix:  rules.Prefix,
+			suffix:  rules.Suffix,
+		}
+		for i, r := range rules.Replace {
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				return YAML{}, fmt.Errorf("domain %s: replace %d: invalid regex %s: %s", domain, i+1, r.Regex, err)
+			}
+			d.replace[i] = yamlReplace{re: re, with: r.With}
+		}
+		switch strings.ToLower(rules.Case) {
+		case "":
+		case "lower":
+			d.toCase = strings.ToLower
+		case "upper":
+			d.toCase = strings.ToUpper
+		default:
+			return YAML{}, fmt.Errorf("domain %s: invalid case: %s: valid values: lower, upper", domain, rules.Case)
+		}
+		tr.domains[domain] = d
+	}
+	return tr, nil
+}
+
+func (tr YAML) Translate(domain, metric string) string {
+	d, ok := tr.domains[domain]
+	if !ok {
+		d, ok = tr.domains[ANY_DOMAIN]
+		if !ok {
+			return domain + "." + metric
+		}
+	}
+	if name, ok := d.metrics[metric]; ok {
+		return name
+	}
+	for _, r := range d.replace {
+		metric = r.re.ReplaceAllString(metric, r.with)
+	}
+	if d.toCase != nil {
+		metric = d.toCase(metric)
+	}
+	return strings.ReplaceAll(d.prefix, "{domain}", domain) + metric +
+		strings.ReplaceAll(d.suffix, "{domain}", domain)
+}
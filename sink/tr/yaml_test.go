@@ -0,0 +1,128 @@
+// Copyright 2022 Block, Inc.
+
+package tr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cashapp/blip/sink/tr"
+)
+
+const testYAML = `
+domains:
+  status.global:
+    metrics:
+      threads_running: mysql.threads.running
+    replace:
+      - regex: "^com_(.+)$"
+        with: "command.$1"
+    case: upper
+    prefix: "mysql.status."
+  "*":
+    prefix: "mysql.{domain}."
+    suffix: ".v1"
+`
+
+func TestYAML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tr.yaml")
+	if err := ioutil.WriteFile(file, []byte(testYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loaded by any sink via metric-translator: file:<file>
+	got, err := tr.Make(tr.FILE_PREFIX + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tr.Exists(tr.FILE_PREFIX + file) {
+		t.Errorf("YAML translator not registered after Make")
+	}
+
+	tests := []struct {
+		domain, metric, expect string
+	}{
+		{"status.global", "threads_running", "mysql.threads.running"}, // exact
+		{"status.global", "com_select", "mysql.status.COMMAND.SELECT"},
+		{"status.global", "queries", "mysql.status.QUERIES"},
+		{"var.global", "max_connections", "mysql.var.global.max_connections.v1"}, // *
+	}
+	for _, test := range tests {
+		if name := got.Translate(test.domain, test.metric); name != test.expect {
+			t.Errorf("%s.%s: got %s, expected %s", test.domain, test.metric, name, test.expect)
+		}
+	}
+
+	// Without * rules, domains without rules are not renamed
+	yaml, err := tr.NewYAML(tr.YAMLFile{
+		Domains: map[string]tr.YAMLDomain{"innodb": {Case: "lower"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := yaml.Translate("var.global", "max_connections"); name != "var.global.max_connections" {
+		t.Errorf("got %s, expected var.global.max_connections", name)
+	}
+}
+
+func TestYAMLInvalid(t *testing.T) {
+	invalid := []tr.YAMLDomain{
+		{Case: "title"},
+		{Replace: []tr.YAMLReplace{{Regex: "(", With: "x"}}},
+	}
+	for _, d := range invalid {
+		if _, err := tr.NewYAML(tr.YAMLFile{Domains: map[string]tr.YAMLDomain{"d": d}}); err == nil {
+			t.Errorf("%+v: no error, expected an error", d)
+		}
+	}
+	if _, err := tr.Make(tr.FILE_PREFIX + "/does/not/exist.yaml"); err == nil {
+		t.Errorf("no error for missing file, expected an error")
+	}
+}
+
+func TestYAMLReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tr.yaml")
+	if err := ioutil.WriteFile(file, []byte(testYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := tr.FILE_PREFIX + file
+
+	if _, err := tr.Make(name); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modify the file but not its mtime: cached translator is used
+	if err := ioutil.WriteFile(file, []byte("domains:\n  \"*\":\n    prefix: \"new.\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	tr2, err := tr.Make(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tr2.Translate("var.global", "max_connections"); got != "mysql.var.global.max_connections.v1" {
+		t.Errorf("got %s, expected cached translator name mysql.var.global.max_connections.v1", got)
+	}
+
+	// Modified file, like before a reload: next Make loads the new file
+	mtime := fi.ModTime().Add(time.Second)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	tr3, err := tr.Make(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tr3.Translate("var.global", "max_connections"); got != "new.max_connections" {
+		t.Errorf("got %s, expected new.max_connections", got)
+	}
+}
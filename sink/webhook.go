@@ -0,0 +1,255 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	DEFAULT_WEBHOOK_METHOD       = "POST"
+	DEFAULT_WEBHOOK_CONTENT_TYPE = "application/json"
+)
+
+// WebhookPayload is the data for the webhook body. Without a template, the body
+// is WebhookPayload as JSON. With a template, it's the template data: for
+// example, {{range .Metrics}}{{.MonitorId}}{{end}}. Without batching, Metrics
+// has one element.
+type WebhookPayload struct {
+	Metrics []WebhookMetrics
+}
+
+// WebhookMetrics is FileMetrics (the file sink JSON object) and the monitor tags.
+type WebhookMetrics struct {
+	FileMetrics
+	Tags map[string]string `json:",omitempty"`
+}
+
+// Webhook sends metrics to an arbitrary HTTP endpoint. The body is JSON
+// (WebhookPayload) or a user-provided Go text/template.
+type Webhook struct {
+	monitorId string
+	tags      map[string]string // monitor.tags
+	// --
+	url         string
+	method      string
+	contentType string
+	username    string
+	password    string
+	bearerToken string
+	headers     map[string]string
+	tmpl        *template.Template
+	debug       bool
+	client      *http.Client
+}
+
+// Template functions in addition to the text/template built-in functions
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		bytes, err := json.Marshal(v)
+		return string(bytes), err
+	},
+	"unix": func(t time.Time) int64 {
+		return t.Unix()
+	},
+	"unixMilli": func(t time.Time) int64 {
+		return t.UnixMilli()
+	},
+}
+
+func NewWebhook(monitorId string, opts, tags map[string]string, httpClient *http.Client) (*Webhook, error) {
+	s := &Webhook{
+		monitorId: monitorId,
+		tags:      tags,
+		// --
+		method:      DEFAULT_WEBHOOK_METHOD,
+		contentType: DEFAULT_WEBHOOK_CONTENT_TYPE,
+		headers:     map[string]string{},
+		client:      httpClient, // made by blip.Factory.HTTPClient
+	}
+
+	var tmpl, tmplFile string
+	for k, v := range opts {
+		switch k {
+		case "url":
+			s.url = v
+		case "method":
+			s.method = strings.ToUpper(v)
+			switch s.method {
+			case "POST", "PUT", "PATCH":
+			default:
+				return nil, fmt.Errorf("invalid webhook sink method: %s: valid values: POST, PUT, PATCH", v)
+			}
+		case "content-type":
+			s.contentType = v
+		case "username":
+			s.username = v
+		case "password":
+			s.password = v
+		case "bearer-token":
+			s.bearerToken = v
+		case "bearer-token-file":
+			bytes, err := ioutil.ReadFile(v)
+			if err != nil {
+				return nil, err
+			}
+			s.bearerToken = strings.TrimSpace(string(bytes))
+		case "headers":
+			headers, err := parseHeaders("webhook", v)
+			if err != nil {
+				return nil, err
+			}
+			s.headers = headers
+		case "template":
+			tmpl = v
+		case "template-file":
+			tmplFile = v
+		case "debug":
+			s.debug = blip.Bool(v)
+		default:
+			if blip.Strict {
+				return nil, fmt.Errorf("invalid option: %s", k)
+			}
+		}
+	}
+
+	if s.url == "" {
+		return nil, fmt.Errorf("webhook sink requires url")
+	}
+	if s.bearerToken != "" && (s.username != "" || s.password != "") {
+		return nil, fmt.Errorf("webhook sink bearer-token and username/password are mutually exclusive")
+	}
+	if tmpl != "" && tmplFile != "" {
+		return nil, fmt.Errorf("webhook sink template and template-file are mutually exclusive")
+	}
+	if tmplFile != "" {
+		bytes, err := ioutil.ReadFile(tmplFile)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = string(bytes)
+	}
+	if tmpl != "" {
+		t, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook sink template: %s", err)
+		}
+		s.tmpl = t
+	}
+	if s.client == nil {
+		s.client = &http.Client{}
+	}
+
+	return s, nil
+}
+
+func (s *Webhook) Send(ctx context.Context, m *blip.Metrics) (lerr error) {
+	status.Monitor(s.monitorId, "webhook", "sending metrics from %s", m.Begin)
+
+	n := 0
+	defer func() {
+		if lerr == nil {
+			status.Monitor(s.monitorId, "webhook", "last sent %d metrics at %s", n, time.Now())
+		} else {
+			status.Monitor(s.monitorId, "webhook", "error on last send at %s: %s", time.Now(), lerr)
+		}
+	}()
+
+	fm, n := NewFileMetrics(m)
+	if n == 0 {
+		return // success (nothing to send)
+	}
+	lerr = s.send(ctx, WebhookPayload{
+		Metrics: []WebhookMetrics{{FileMetrics: fm, Tags: s.tags}},
+	})
+	return // implicit lerr
+}
+
+// SendBatch sends metrics from many monitors in one request. It implements
+// BatchSender.
+func (s *Webhook) SendBatch(ctx context.Context, items []BatchItem) error {
+	payload := WebhookPayload{
+		Metrics: make([]WebhookMetrics, 0, len(items)),
+	}
+	for _, item := range items {
+		fm, n := NewFileMetrics(item.Metrics)
+		if n == 0 {
+			continue
+		}
+		payload.Metrics = append(payload.Metrics, WebhookMetrics{FileMetrics: fm, Tags: item.Tags})
+	}
+	if len(payload.Metrics) == 0 {
+		return nil
+	}
+	return s.send(ctx, payload)
+}
+
+func (s *Webhook) Name() string {
+	return "webhook"
+}
+
+// send renders the body and sends the request.
+func (s *Webhook) send(ctx context.Context, payload WebhookPayload) error {
+	var body []byte
+	if s.tmpl != nil {
+		var buf bytes.Buffer
+		if err := s.tmpl.Execute(&buf, payload); err != nil {
+			return fmt.Errorf("webhook template: %s", err)
+		}
+		body = buf.Bytes()
+	} else {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	// If config.sinks.webhook.debug=true, then just print via debug, don't send
+	if s.debug {
+		blip.Debug("%s: %s %s: %s", s.monitorId, s.method, s.url, body)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	req.Header.Set("User-Agent", "blip/"+blip.VERSION)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response to %s: %s", s.method, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook HTTP response code %d, expected 2xx: %s",
+			resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Block, Inc.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var gotReq *http.Request
+	var gotBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	opts := map[string]string{
+		"url":          ts.URL,
+		"method":       "put",
+		"bearer-token": "abc",
+		"headers":      "X-Team: dba",
+	}
+	s, err := NewWebhook("m1", opts, map[string]string{"env": "prod"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := testMetrics()
+	m.MonitorId = "m1"
+	m.Begin = time.Unix(1650000000, 0).UTC()
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotReq.Method != "PUT" {
+		t.Errorf("method %s, expected PUT", gotReq.Method)
+	}
+	expectHeaders := map[string]string{
+		"Authorization": "Bearer abc",
+		"Content-Type":  "application/json",
+		"X-Team":        "dba",
+	}
+	for k, v := range expectHeaders {
+		if got := gotReq.Header.Get(k); got != v {
+			t.Errorf("header %s = %q, expected %q", k, got, v)
+		}
+	}
+
+	var got WebhookPayload
+	if err := json.Unmarshal(gotBody, &got); err != nil {
+		t.Fatalf("%s: %s", err, gotBody)
+	}
+	fm, _ := NewFileMetrics(m)
+	expect := WebhookPayload{
+		Metrics: []WebhookMetrics{{FileMetrics: fm, Tags: map[string]string{"env": "prod"}}},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer ts.Close()
+
+	opts := map[string]string{
+		"url":          ts.URL,
+		"content-type": "text/plain",
+		"template": `{{range .Metrics}}{{$m := .}}{{range index .Values "status.global"}}` +
+			`{{$m.MonitorId}} {{$m.Tags.env}} {{.Name}}={{.Value}} {{unix $m.Begin}}` + "\n" +
+			`{{end}}{{end}}`,
+	}
+	s, err := NewWebhook("m1", opts, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Batch of 2 monitors
+	items := []BatchItem{}
+	for _, id := range []string{"m1", "m2"} {
+		items = append(items, BatchItem{
+			Metrics: &blip.Metrics{
+				MonitorId: id,
+				Begin:     time.Unix(1650000000, 0),
+				Values: map[string][]blip.MetricValue{
+					"status.global": {{Name: "queries", Type: blip.COUNTER, Value: 10}},
+				},
+			},
+			Tags: map[string]string{"env": id + "-env"},
+		})
+	}
+	if err := s.SendBatch(context.Background(), items); err != nil {
+		t.Fatal(err)
+	}
+	expect := "m1 m1-env queries=10 1650000000\nm2 m2-env queries=10 1650000000\n"
+	if gotBody != expect {
+		t.Errorf("got body:\n%s\nexpected:\n%s", gotBody, expect)
+	}
+}
+
+func TestWebhookOptions(t *testing.T) {
+	invalid := []map[string]string{
+		{}, // url required
+		{"url": "http://x", "method": "GET"},
+		{"url": "http://x", "template": "{{.Metrics"},
+		{"url": "http://x", "bearer-token": "a", "username": "u"},
+	}
+	for _, opts := range invalid {
+		if _, err := NewWebhook("m1", opts, nil, nil); err == nil {
+			t.Errorf("%v: no error, expected an error", opts)
+		}
+	}
+}
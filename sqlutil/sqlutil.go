@@ -169,3 +169,40 @@ func RowToMap(ctx context.Context, db *sql.DB, query string) (map[string]string,
 
 	return m, nil
 }
+
+// RowsToMaps is like RowToMap but returns all rows from query. This is used
+// for command outputs that return one row per object, like SHOW REPLICA STATUS
+// which returns one row per replication channel. It returns nil if the query
+// returns zero rows.
+func RowsToMaps(ctx context.Context, db *sql.DB, query string) ([]map[string]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	scanArgs := make([]interface{}, len(columns))
+	values := make([]sql.RawBytes, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var all []map[string]string
+	for rows.Next() {
+		if err = rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		m := make(map[string]string, len(columns))
+		for i, col := range columns {
+			m[col] = string(values[i]) // copy because RawBytes are reused
+		}
+		all = append(all, m)
+	}
+
+	return all, rows.Err()
+}
@@ -2,13 +2,17 @@
 
 package mock
 
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
 type Exporter struct {
-	ScrapeFunc func() (string, error)
+	GatherFunc func() ([]*dto.MetricFamily, error)
 }
 
-func (e Exporter) Scrape() (string, error) {
-	if e.ScrapeFunc != nil {
-		return e.ScrapeFunc()
+func (e Exporter) Gather() ([]*dto.MetricFamily, error) {
+	if e.GatherFunc != nil {
+		return e.GatherFunc()
 	}
-	return "", nil
+	return nil, nil
 }